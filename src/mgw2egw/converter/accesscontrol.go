@@ -0,0 +1,111 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"fmt"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const accessControlName string = "Access-Control-1"
+
+// accessControlConverter turns the allow and deny lists into an AccessControl
+// policy that runs ahead of every other step, so blocked clients are
+// rejected before authentication
+type accessControlConverter struct{}
+
+func init() {
+	Register(accessControlConverter{})
+}
+
+func (accessControlConverter) Name() string {
+	return "accesscontrol"
+}
+
+func (accessControlConverter) Section() string {
+	return "accesscontrol"
+}
+
+func (accessControlConverter) Convert(context Context) ([]Policy, error) {
+	accessControl := mgconfig.GetAccessControlDetails(context.Config)
+
+	noRuleMatchAction := "ALLOW"
+	switch strings.ToLower(accessControl.NoRuleMatchAction) {
+	case "", "allow":
+	case "deny":
+		noRuleMatchAction = "DENY"
+	default:
+		return nil, fmt.Errorf("accesscontrol.noRuleMatchAction must be allow or deny, found %s", accessControl.NoRuleMatchAction)
+	}
+
+	allow := sourceAddresses(context, accessControl.Allow)
+	deny := sourceAddresses(context, accessControl.Deny)
+
+	policy := policies.NewAccessControl(accessControlName, noRuleMatchAction)
+	rules := []policies.MatchRule{{Action: "ALLOW", SourceAddress: allow}, {Action: "DENY", SourceAddress: deny}}
+	if accessControl.DenyFirst {
+		rules[0], rules[1] = rules[1], rules[0]
+	}
+	for _, rule := range rules {
+		if len(rule.SourceAddress) > 0 {
+			policy.AddMatchRule(rule.Action, rule.SourceAddress...)
+		}
+	}
+
+	converted, err := NewPolicy(accessControlName, policy, ProxyEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	converted.First = true
+	return []Policy{converted}, nil
+}
+
+// sourceAddresses converts addresses such as 10.1.2.3, 10.1.0.0/16 or
+// 10.1.*.* to an address and a mask. A wildcard is only allowed in the
+// trailing octets, where it can be expressed as a mask. The other entries
+// are left out with a warning.
+func sourceAddresses(context Context, addresses []string) []policies.SourceAddress {
+	var result []policies.SourceAddress
+	for _, address := range addresses {
+		ip, mask := address, 32
+		if i := strings.Index(address, "/"); i >= 0 {
+			var err error
+			ip = address[:i]
+			mask, err = strconv.Atoi(address[i+1:])
+			if err != nil || mask < 0 || mask > 32 {
+				context.warn(fmt.Sprintf("accesscontrol: invalid mask in %s, the entry is left out", address))
+				continue
+			}
+		} else {
+			octets := strings.Split(address, ".")
+			wildcards := 0
+			for i := len(octets) - 1; i >= 0 && octets[i] == "*"; i-- {
+				octets[i] = "0"
+				wildcards++
+			}
+			ip, mask = strings.Join(octets, "."), 32-8*wildcards
+		}
+		if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+			context.warn(fmt.Sprintf("accesscontrol: %s is not an IPv4 address, or a wildcard that can be expressed as a mask, the entry is left out", address))
+			continue
+		}
+		result = append(result, policies.SourceAddress{Mask: mask, Value: ip})
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"gopkg.in/yaml.v2"
+	"log"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// accessControlPolicy converts an accesscontrol section and returns the
+// AccessControl policy and the warnings
+func accessControlPolicy(t *testing.T, section string) (policies.AccessControl, string) {
+	var microgateway mgconfig.Microgateway
+	if err := yaml.Unmarshal([]byte("accesscontrol:\n"+section), &microgateway); err != nil {
+		t.Fatal(err)
+	}
+	var warnings bytes.Buffer
+	result, err := accessControlConverter{}.Convert(Context{Config: microgateway, ProxyName: "edgemicro_test", Warning: log.New(&warnings, "", 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var accessControl policies.AccessControl
+	if err := xml.Unmarshal(findPolicy(t, result, accessControlName).Content, &accessControl); err != nil {
+		t.Fatal(err)
+	}
+	return accessControl, warnings.String()
+}
+
+func TestAccessControlAddresses(t *testing.T) {
+	accessControl, warnings := accessControlPolicy(t, `
+  allow:
+    - 10.1.2.3
+    - 10.1.0.0/16
+    - 192.168.*.*
+`)
+	if warnings != "" {
+		t.Errorf("warnings = %q", warnings)
+	}
+	want := []policies.MatchRule{{Action: "ALLOW", SourceAddress: []policies.SourceAddress{
+		{Mask: 32, Value: "10.1.2.3"},
+		{Mask: 16, Value: "10.1.0.0"},
+		{Mask: 16, Value: "192.168.0.0"},
+	}}}
+	if !reflect.DeepEqual(accessControl.IPRules.MatchRule, want) {
+		t.Errorf("rules = %+v, want %+v", accessControl.IPRules.MatchRule, want)
+	}
+	if accessControl.IPRules.NoRuleMatchAction != "ALLOW" {
+		t.Errorf("noRuleMatchAction = %s, want ALLOW", accessControl.IPRules.NoRuleMatchAction)
+	}
+}
+
+func TestAccessControlInvalidAddresses(t *testing.T) {
+	accessControl, warnings := accessControlPolicy(t, `
+  deny:
+    - not-an-address
+    - 10.0.0.0/40
+    - 10.*.1.1
+    - 2001:db8::1
+    - 10.9.8.7
+`)
+	for _, address := range []string{"not-an-address", "10.0.0.0/40", "10.*.1.1", "2001:db8::1"} {
+		if !strings.Contains(warnings, address) {
+			t.Errorf("no warning for %s in %q", address, warnings)
+		}
+	}
+	want := []policies.MatchRule{{Action: "DENY", SourceAddress: []policies.SourceAddress{{Mask: 32, Value: "10.9.8.7"}}}}
+	if !reflect.DeepEqual(accessControl.IPRules.MatchRule, want) {
+		t.Errorf("rules = %+v, want %+v", accessControl.IPRules.MatchRule, want)
+	}
+}
+
+func TestAccessControlAllowAndDeny(t *testing.T) {
+	tests := []struct {
+		section string
+		actions []string
+	}{
+		{"  allow:\n    - 10.1.0.0/16\n  deny:\n    - 10.1.2.3\n  noRuleMatchAction: deny\n", []string{"ALLOW", "DENY"}},
+		{"  deny:\n    - 10.1.2.3\n  allow:\n    - 10.1.0.0/16\n  noRuleMatchAction: deny\n", []string{"DENY", "ALLOW"}},
+	}
+	for _, test := range tests {
+		accessControl, _ := accessControlPolicy(t, test.section)
+		var actions []string
+		for _, rule := range accessControl.IPRules.MatchRule {
+			actions = append(actions, rule.Action)
+		}
+		if !reflect.DeepEqual(actions, test.actions) {
+			t.Errorf("rules of\n%s are in the order %v, want %v", test.section, actions, test.actions)
+		}
+		if accessControl.IPRules.NoRuleMatchAction != "DENY" {
+			t.Errorf("noRuleMatchAction = %s, want DENY", accessControl.IPRules.NoRuleMatchAction)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+// analyticsConverter emits no policies, Apigee Edge collects analytics for every proxy
+type analyticsConverter struct{}
+
+func init() {
+	Register(analyticsConverter{})
+}
+
+func (analyticsConverter) Name() string {
+	return "analytics"
+}
+
+func (analyticsConverter) Section() string {
+	return "analytics"
+}
+
+func (analyticsConverter) Convert(context Context) ([]Policy, error) {
+	return nil, nil
+}
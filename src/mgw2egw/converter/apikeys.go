@@ -0,0 +1,55 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+)
+
+// apiKeysPolicyName differs from the VerifyAPIKey policy of oauth, so both
+// plugins can be in the sequence
+const apiKeysPolicyName string = "Verify-API-Key-APIKeys-1"
+
+// apiKeysConverter verifies the API key sent in the header configured with
+// api-key-header, x-api-key by default
+type apiKeysConverter struct{}
+
+func init() {
+	Register(apiKeysConverter{})
+}
+
+func (apiKeysConverter) Name() string {
+	return "apikeys"
+}
+
+func (apiKeysConverter) Section() string {
+	return "apikeys"
+}
+
+func (apiKeysConverter) Convert(context Context) ([]Policy, error) {
+	apiKeys := mgconfig.GetAPIKeysDetails(context.Config)
+	verifyAPIKey := policies.NewVerifyAPIKey(apiKeysPolicyName, apiKeyRef(apiKeys.ApiKeyHeader))
+	verifyAPIKey.ContinueOnError = apiKeys.AllowInvalidAuthorization
+
+	result, err := newPolicies(namedPolicy{apiKeysPolicyName, verifyAPIKey})
+	if err != nil {
+		return nil, err
+	}
+	if apiKeys.AllowNoAuthorization {
+		result = onlyWithHeader(result, apiKeyHeaderName(apiKeys.ApiKeyHeader))
+	}
+	return result, nil
+}
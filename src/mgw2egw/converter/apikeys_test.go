@@ -0,0 +1,45 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"testing"
+)
+
+func TestAPIKeysWithOAuthAPIKeyOnly(t *testing.T) {
+	config := `
+edgemicro:
+  plugins:
+    sequence:
+      - oauth
+      - apikeys
+oauth:
+  allowAPIKeyOnly: true
+apikeys:
+  api-key-header: x-app-key
+`
+	names := map[string]string{}
+	for _, plugin := range []string{"oauth", "apikeys"} {
+		for _, policy := range convertConfig(t, plugin, config) {
+			if other, ok := names[policy.Name]; ok {
+				t.Errorf("policy %s is generated by %s and %s", policy.Name, other, plugin)
+			}
+			names[policy.Name] = plugin
+		}
+	}
+	if names[verifyApiKeyName] != "oauth" || names[apiKeysPolicyName] != "apikeys" {
+		t.Errorf("policies = %v, want %s from oauth and %s from apikeys", names, verifyApiKeyName, apiKeysPolicyName)
+	}
+}
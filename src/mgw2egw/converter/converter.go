@@ -0,0 +1,193 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"log"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+	"sort"
+	"strings"
+)
+
+// Endpoint is the kind of endpoint a policy is attached to
+type Endpoint int
+
+const (
+	// ProxyEndpoint attaches the policy to every ProxyEndpoint (northbound)
+	ProxyEndpoint Endpoint = iota
+	// TargetEndpoint attaches the policy to every TargetEndpoint (southbound)
+	TargetEndpoint
+)
+
+// PreFlow and PostFlow are the flows a policy can be attached to, besides
+// the conditional flows returned by a FlowConverter
+const (
+	PreFlow  string = "PreFlow"
+	PostFlow string = "PostFlow"
+)
+
+// Policy is an Edge policy generated from a microgateway plugin
+type Policy struct {
+	//Name of the policy, the policy is written to apiproxy/policies/<Name>.xml
+	Name     string
+	Content  []byte
+	Endpoint Endpoint
+	//Flow is PreFlow (when empty), PostFlow or the name of a conditional flow
+	Flow string
+	//Response attaches the policy to the response instead of the request
+	Response bool
+	//Condition of the step, "" if the policy always runs
+	Condition string
+	//First places the step ahead of the steps already in the flow, including
+	//the steps of the plugins converted before it
+	First bool
+	//FaultRule also attaches the policy to the DefaultFaultRule of the
+	//endpoint, so it runs on the errors raised by the proxy too
+	FaultRule bool
+	//Script is the JavaScript resource of a Javascript policy, written to
+	//apiproxy/resources/jsc/<Name>.js
+	Script []byte
+}
+
+// ConditionalFlow is a conditional flow added to every ProxyEndpoint, ahead
+// of the flows already in the bundle
+type ConditionalFlow struct {
+	Name      string
+	Condition string
+	//NoRoute adds a RouteRule without a TargetEndpoint, so the proxy answers
+	//matching requests without calling the target
+	NoRoute bool
+	//Bypass skips the request steps of the plugins that come later in the
+	//sequence, the way a plugin that ends the response does in microgateway
+	Bypass bool
+}
+
+// Context is passed to every converter
+type Context struct {
+	Config    mgconfig.Microgateway
+	ProxyName string
+	UseJWT    bool
+	//Warning logs where a conversion does not behave exactly like the plugin, it can be nil
+	Warning *log.Logger
+}
+
+// warn logs a difference between a plugin and its conversion, with the
+// file and line of the converter that calls it
+func (context Context) warn(message string) {
+	if context.Warning != nil {
+		context.Warning.Output(2, message)
+	}
+}
+
+// Converter maps a microgateway plugin to Edge policies
+type Converter interface {
+	// Name of the plugin, as listed in edgemicro.plugins.sequence
+	Name() string
+	// Section of the microgateway configuration file the plugin reads, "" if none
+	Section() string
+	// Convert returns the policies for the plugin, in the order they are attached
+	Convert(context Context) ([]Policy, error)
+}
+
+// FlowConverter is implemented by converters that also add conditional flows
+type FlowConverter interface {
+	Converter
+	// Flows returns the conditional flows for the plugin, in the order they are evaluated
+	Flows(context Context) ([]ConditionalFlow, error)
+}
+
+var registry = map[string]Converter{}
+
+// Register adds a converter to the registry. A converter registered with the
+// same name as a built-in converter replaces it. Custom converters are
+// usually registered from an init function.
+func Register(converter Converter) {
+	registry[converter.Name()] = converter
+}
+
+// Get returns the converter for a plugin
+func Get(plugin string) (Converter, bool) {
+	converter, ok := registry[plugin]
+	return converter, ok
+}
+
+// Names returns the sorted names of the registered converters
+func Names() []string {
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPolicy marshals a policy built with the policies package
+func NewPolicy(name string, policy interface{}, endpoint Endpoint) (Policy, error) {
+	content, err := policies.Marshal(policy)
+	if err != nil {
+		return Policy{}, err
+	}
+	return Policy{Name: name, Content: content, Endpoint: endpoint}, nil
+}
+
+// And joins conditions so that all of them must be true, empty conditions are ignored
+func And(conditions ...string) string {
+	var parts []string
+	for _, condition := range conditions {
+		if condition != "" {
+			parts = append(parts, condition)
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	for i, part := range parts {
+		parts[i] = "(" + part + ")"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// Not negates a condition
+func Not(condition string) string {
+	return "!(" + condition + ")"
+}
+
+// newPolicies marshals the policies attached to the ProxyEndpoints
+func newPolicies(named ...namedPolicy) ([]Policy, error) {
+	var result []Policy
+	for _, policy := range named {
+		converted, err := NewPolicy(policy.name, policy.policy, ProxyEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+type namedPolicy struct {
+	name   string
+	policy interface{}
+}
+
+func hasPlugin(config mgconfig.Microgateway, plugin string) bool {
+	for _, name := range mgconfig.GetPlugins(config) {
+		if name == plugin {
+			return true
+		}
+	}
+	return false
+}
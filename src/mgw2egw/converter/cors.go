@@ -0,0 +1,98 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+	"strconv"
+)
+
+const (
+	addCorsName   string = "Add-CORS-1"
+	corsFlowName  string = "CORS-Preflight"
+	corsCondition string = `request.verb = "OPTIONS" and request.header.origin != null and request.header.Access-Control-Request-Method != null`
+)
+
+// defaults used by the microgateway cors plugin
+const (
+	corsOrigin       string = "*"
+	corsMethods      string = "GET, PUT, POST, DELETE, PATCH, OPTIONS"
+	corsAllowHeaders string = "Origin, X-Requested-With, Content-Type, Accept, Authorization"
+	corsMaxAge       int    = 3628800
+)
+
+// corsConverter answers preflight requests from the proxy, without calling
+// the target or running the plugins that come later, and adds the CORS
+// headers to every response
+type corsConverter struct{}
+
+func init() {
+	Register(corsConverter{})
+}
+
+func (corsConverter) Name() string {
+	return "cors"
+}
+
+func (corsConverter) Section() string {
+	return "cors"
+}
+
+func (corsConverter) Convert(context Context) ([]Policy, error) {
+	cors := mgconfig.GetCorsDetails(context.Config)
+	if cors.Origin == "" {
+		cors.Origin = corsOrigin
+	}
+	if cors.Methods == "" {
+		cors.Methods = corsMethods
+	}
+	if cors.AllowHeaders == "" {
+		cors.AllowHeaders = corsAllowHeaders
+	}
+	if cors.MaxAge == 0 {
+		cors.MaxAge = corsMaxAge
+	}
+
+	//browsers reject a wildcard origin on credentialed requests, echo the caller instead
+	origin := cors.Origin
+	if origin == "*" && cors.AllowCredentials {
+		origin = "{request.header.origin}"
+	}
+
+	assignMessage := policies.NewAssignMessage(addCorsName, "response").
+		SetHeader("Access-Control-Allow-Origin", origin).
+		SetHeader("Access-Control-Allow-Methods", cors.Methods).
+		SetHeader("Access-Control-Allow-Headers", cors.AllowHeaders).
+		SetHeader("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+	if cors.AllowCredentials {
+		assignMessage.SetHeader("Access-Control-Allow-Credentials", "true")
+	}
+
+	policy, err := NewPolicy(addCorsName, assignMessage, ProxyEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	policy.Flow = PostFlow
+	policy.Response = true
+	//errors such as a 401 from the security policies need the headers too,
+	//or browsers report a CORS error instead of the status
+	policy.FaultRule = true
+	return []Policy{policy}, nil
+}
+
+func (corsConverter) Flows(context Context) ([]ConditionalFlow, error) {
+	return []ConditionalFlow{{Name: corsFlowName, Condition: corsCondition, NoRoute: true, Bypass: true}}, nil
+}
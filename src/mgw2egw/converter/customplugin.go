@@ -0,0 +1,409 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// handlerPattern finds the handlers of a plugin, written as properties
+// (onrequest: function(req, res, next) {), arrow functions or methods
+var handlerPattern = regexp.MustCompile(`\b(onrequest|ondata_request|onend_request|onresponse|ondata_response|onend_response)\s*(?::\s*(?:async\s+)?(?:function\b\s*[\w$]*\s*)?)?\(([^)]*)\)\s*(?:=>\s*)?\{`)
+
+// modulePattern finds the variables that hold a module loaded with require
+var modulePattern = regexp.MustCompile(`\b(?:var|let|const)\s+([A-Za-z_$][\w$]*)\s*=\s*require\s*\(`)
+
+// handler is the part of a plugin that runs for one event
+type handler struct {
+	event string
+	//params of the handler, in the order microgateway passes them
+	params []string
+	body   string
+}
+
+// handlerSide is the message that the handlers of a policy see
+type handlerSide struct {
+	suffix  string
+	message string
+	events  []string
+}
+
+var handlerSides = []handlerSide{
+	{"Request", "request", []string{"onrequest", "ondata_request", "onend_request"}},
+	{"Response", "response", []string{"onresponse", "ondata_response", "onend_response"}},
+}
+
+// nodeOnly flags the code that has no equivalent in the Edge JavaScript
+// object model, the message is added as a TODO above the line
+var nodeOnly = []struct {
+	pattern *regexp.Regexp
+	message string
+}{
+	{regexp.MustCompile(`\brequire\s*\(`), "require is not available in Edge, add the code of the module as another jsc resource"},
+	{regexp.MustCompile(`\bprocess\.`), "process is Node.js only, use flow variables or a KVM"},
+	{regexp.MustCompile(`\bBuffer\b`), "Buffer is Node.js only, the content is a string"},
+	{regexp.MustCompile(`\b(setTimeout|setInterval|setImmediate)\s*\(`), "timers are not available in Edge, the script must finish synchronously"},
+	{regexp.MustCompile(`\b(__dirname|__filename)\b`), "there is no file system in Edge"},
+	{regexp.MustCompile(`\bconfig\b`), "config is the plugin section of the microgateway configuration, use properties or a KVM"},
+	{regexp.MustCompile(`\b(logger|stats)\.`), "the microgateway logger and stats are not available, use print or a MessageLogging policy"},
+}
+
+// customPluginConverter turns the handlers of a custom Node.js plugin into
+// Javascript policies. The result is a scaffold to review rather than a
+// working conversion, which is why it is reported with a warning
+type customPluginConverter struct {
+	name  string
+	index string
+}
+
+// CustomPlugin returns a converter for a plugin that has an index.js in
+// edgemicro.plugins.dir, or false when there is none
+func CustomPlugin(config mgconfig.Microgateway, plugin string) (Converter, bool) {
+	dir := mgconfig.GetPluginsDir(config)
+	if dir == "" {
+		return nil, false
+	}
+	index := filepath.Join(dir, plugin, "index.js")
+	if _, err := os.Stat(index); err != nil {
+		return nil, false
+	}
+	return customPluginConverter{name: plugin, index: index}, true
+}
+
+func (plugin customPluginConverter) Name() string {
+	return plugin.name
+}
+
+func (plugin customPluginConverter) Section() string {
+	return plugin.name
+}
+
+// Convert returns a Javascript policy in the PreFlow request for the request
+// handlers of the plugin and one in the PostFlow response for the response
+// handlers, with the handlers in a jsc resource
+func (plugin customPluginConverter) Convert(context Context) ([]Policy, error) {
+	source, err := ioutil.ReadFile(plugin.index)
+	if err != nil {
+		return nil, err
+	}
+	handlers, err := findHandlers(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", plugin.index, err)
+	}
+	if len(handlers) == 0 {
+		context.warn(fmt.Sprintf("%s: no request or response handler found, the plugin has to be reimplemented manually", plugin.index))
+		return nil, nil
+	}
+
+	var result []Policy
+	for _, side := range handlerSides {
+		var sideHandlers []handler
+		for _, handler := range handlers {
+			if contains(side.events, handler.event) {
+				sideHandlers = append(sideHandlers, handler)
+			}
+		}
+		if len(sideHandlers) == 0 {
+			continue
+		}
+
+		name := "Javascript-" + policyNameChars.ReplaceAllString(plugin.name, "-") + "-" + side.suffix
+		script, todos := handlerScript(plugin.name, plugin.index, side, sideHandlers, modules(blankLiterals(string(source))))
+		policy, err := NewPolicy(name, policies.NewJavascriptResource(name, name+".js"), ProxyEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		if side.message == "response" {
+			policy.Flow = PostFlow
+			policy.Response = true
+		}
+		policy.Script = script
+		result = append(result, policy)
+		context.warn(fmt.Sprintf("plugin %s: %s.js is a scaffold generated from %s, review it and its %d TODO markers before deploying", plugin.name, name, plugin.index, todos))
+	}
+	return result, nil
+}
+
+// findHandlers returns the handlers of a plugin in the order they appear in
+// source. Handlers are only looked for in the code, not in strings or comments.
+func findHandlers(source string) ([]handler, error) {
+	code := blankLiterals(source)
+	var handlers []handler
+	offset := 0
+	for {
+		match := handlerPattern.FindStringSubmatchIndex(code[offset:])
+		if match == nil {
+			return handlers, nil
+		}
+		open := offset + match[1] - 1
+		end := blockEnd(code, open)
+		if end < 0 {
+			return nil, fmt.Errorf("the body of %s has no closing brace", source[offset+match[2]:offset+match[3]])
+		}
+		var params []string
+		for _, param := range strings.Split(source[offset+match[4]:offset+match[5]], ",") {
+			if param = strings.TrimSpace(param); param != "" {
+				params = append(params, param)
+			}
+		}
+		handlers = append(handlers, handler{
+			event:  source[offset+match[2] : offset+match[3]],
+			params: params,
+			body:   source[open+1 : end],
+		})
+		offset = end + 1
+	}
+}
+
+// blockEnd returns the position of the brace that closes the one at open in
+// code returned by blankLiterals, or -1 if there is none
+func blockEnd(code string, open int) int {
+	depth := 0
+	for i := open; i < len(code); i++ {
+		switch code[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// blankLiterals replaces the content of the strings and comments of source
+// with spaces, keeping the positions and the line breaks, so that braces and
+// handler names in them are not taken for code
+func blankLiterals(source string) string {
+	code := []byte(source)
+	blank := func(from, to int) {
+		for ; from < to && from < len(code); from++ {
+			if code[from] != '\n' {
+				code[from] = ' '
+			}
+		}
+	}
+	for i := 0; i < len(source); i++ {
+		switch c := source[i]; c {
+		case '\'', '"', '`':
+			start := i + 1
+			for i++; i < len(source) && source[i] != c; i++ {
+				if source[i] == '\\' {
+					i++
+				}
+			}
+			blank(start, i)
+		case '/':
+			if strings.HasPrefix(source[i:], "//") {
+				end := strings.IndexByte(source[i:], '\n')
+				if end < 0 {
+					end = len(source) - i
+				}
+				blank(i, i+end)
+				i += end
+			} else if strings.HasPrefix(source[i:], "/*") {
+				end := strings.Index(source[i+2:], "*/")
+				if end < 0 {
+					end = len(source) - i - 2
+				}
+				blank(i, i+end+4)
+				i += end + 3
+			}
+		}
+	}
+	return string(code)
+}
+
+// modules returns the names of the variables that hold a required module
+func modules(source string) []string {
+	var names []string
+	for _, match := range modulePattern.FindAllStringSubmatch(source, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// param returns the name the handler gives to the parameter at index, or
+// the name microgateway uses when the handler does not declare it
+func (handler handler) param(index int, name string) string {
+	if index < len(handler.params) {
+		return handler.params[index]
+	}
+	return name
+}
+
+// handlerScript returns the jsc resource of the handlers of one side, and
+// the number of TODO markers added to it
+func handlerScript(plugin string, index string, side handlerSide, handlers []handler, modules []string) ([]byte, int) {
+	var script bytes.Buffer
+	fmt.Fprintf(&script, "// Generated by mgw2egw from the %s handlers of the %s microgateway plugin\n", side.message, plugin)
+	fmt.Fprintf(&script, "// (%s). Common uses of req and res are rewritten to the context\n", index)
+	fmt.Fprintf(&script, "// object, review the TODO markers for the code that needs a manual change.\n")
+	fmt.Fprintf(&script, "// The data handlers run once with the whole body instead of once per chunk.\n\n")
+
+	fmt.Fprintf(&script, "// nextHandler stands in for the next callback: an error fails the %s\n", side.message)
+	fmt.Fprintf(&script, "// and data replaces the content of the %s\n", side.message)
+	fmt.Fprintf(&script, "function nextHandler(err, data) {\n")
+	fmt.Fprintf(&script, "    if (err) {\n")
+	fmt.Fprintf(&script, "        throw err;\n")
+	fmt.Fprintf(&script, "    }\n")
+	fmt.Fprintf(&script, "    if (data !== undefined && data !== null) {\n")
+	fmt.Fprintf(&script, "        context.setVariable('%s.content', String(data));\n", side.message)
+	fmt.Fprintf(&script, "    }\n")
+	fmt.Fprintf(&script, "}\n")
+
+	todos := 0
+	for _, handler := range handlers {
+		req, res := handler.param(0, "req"), handler.param(1, "res")
+		body, count := markNodeOnly(rewriteHandler(dedent(handler.body), side.message, req, res), req, res, modules)
+		todos += count
+
+		fmt.Fprintf(&script, "\n// %s\n", handler.event)
+		if strings.HasPrefix(handler.event, "ondata") || strings.HasPrefix(handler.event, "onend") {
+			fmt.Fprintf(&script, "(function(%s, %s) {\n", handler.param(2, "data"), handler.param(3, "next"))
+			//microgateway passes the last chunk to the end handlers, the
+			//data handlers have already seen the rest of the body
+			if strings.HasPrefix(handler.event, "onend") {
+				fmt.Fprintf(&script, "    // TODO: %s gets the last chunk of the body in microgateway, here %s is the whole %s content\n", handler.event, handler.param(2, "data"), side.message)
+				todos++
+			}
+			script.WriteString(body)
+			fmt.Fprintf(&script, "})(context.getVariable('%s.content'), nextHandler);\n", side.message)
+		} else {
+			fmt.Fprintf(&script, "(function(%s) {\n", handler.param(2, "next"))
+			script.WriteString(body)
+			fmt.Fprintf(&script, "})(nextHandler);\n")
+		}
+	}
+	return script.Bytes(), todos
+}
+
+// rewriteHandler replaces the common uses of the Node.js req and res objects
+// with the flow variables of the message
+func rewriteHandler(body string, message string, req string, res string) string {
+	req, res = regexp.QuoteMeta(req), regexp.QuoteMeta(res)
+	header := `\.headers(?:\[\s*['"]([^'"]+)['"]\s*\]|\.([A-Za-z_$][\w$]*))`
+
+	//the headers of req are the request headers, the headers of res the
+	//headers of the response, whichever side the handler runs on
+	for _, object := range []struct{ pattern, message string }{{req, "request"}, {res, "response"}} {
+		set := regexp.MustCompile(`\b` + object.pattern + header + `\s*=\s*([^=;\n][^;\n]*);`)
+		body = set.ReplaceAllString(body, "context.setVariable('"+object.message+".header.${1}${2}', ${3});")
+		get := regexp.MustCompile(`\b` + object.pattern + header)
+		body = get.ReplaceAllString(body, "context.getVariable('"+object.message+".header.${1}${2}')")
+	}
+
+	replacements := []struct{ pattern, replacement string }{
+		{`\b` + res + `\.setHeader\(\s*['"]([^'"]+)['"]\s*,`, "context.setVariable('response.header.${1}',"},
+		{`\b` + res + `\.removeHeader\(\s*['"]([^'"]+)['"]\s*\)`, "context.removeVariable('response.header.${1}')"},
+		{`\b` + req + `\.setHeader\(\s*['"]([^'"]+)['"]\s*,`, "context.setVariable('request.header.${1}',"},
+		{`\b` + req + `\.removeHeader\(\s*['"]([^'"]+)['"]\s*\)`, "context.removeVariable('request.header.${1}')"},
+		{`\b` + res + `\.statusCode\s*=\s*([^=;\n][^;\n]*);`, "context.setVariable('response.status.code', ${1});"},
+		{`\b` + res + `\.statusCode\b`, "context.getVariable('response.status.code')"},
+		{`\b` + req + `\.method\b`, "context.getVariable('request.verb')"},
+		{`\b` + req + `\.url\b`, "context.getVariable('request.uri')"},
+	}
+	for _, replacement := range replacements {
+		body = regexp.MustCompile(replacement.pattern).ReplaceAllString(body, replacement.replacement)
+	}
+	return body
+}
+
+// markNodeOnly adds a TODO above the lines that still use Node.js only code,
+// req and res or a required module, and returns the number of TODO markers
+func markNodeOnly(body string, req string, res string, modules []string) (string, int) {
+	leftover := regexp.MustCompile(`\b(` + regexp.QuoteMeta(req) + `|` + regexp.QuoteMeta(res) + `)\b`)
+	var module *regexp.Regexp
+	if len(modules) > 0 {
+		quoted := make([]string, len(modules))
+		for i, name := range modules {
+			quoted[i] = regexp.QuoteMeta(name)
+		}
+		module = regexp.MustCompile(`(?:^|[^\w$.])(` + strings.Join(quoted, "|") + `)\b`)
+	}
+	var marked bytes.Buffer
+	todos := 0
+	for _, line := range strings.SplitAfter(body, "\n") {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		code := strings.TrimSpace(line)
+		if !strings.HasPrefix(code, "//") {
+			for _, check := range nodeOnly {
+				if check.pattern.MatchString(code) {
+					fmt.Fprintf(&marked, "%s// TODO: %s\n", indent, check.message)
+					todos++
+				}
+			}
+			if module != nil && module.MatchString(code) {
+				fmt.Fprintf(&marked, "%s// TODO: %s is a module loaded with require, which is not available in Edge\n", indent, module.FindStringSubmatch(code)[1])
+				todos++
+			}
+			if leftover.MatchString(code) {
+				fmt.Fprintf(&marked, "%s// TODO: convert the use of %s to the context object\n", indent, leftover.FindString(code))
+				todos++
+			}
+		}
+		marked.WriteString(line)
+	}
+	return marked.String(), todos
+}
+
+// dedent removes the blank lines around body and the indentation shared by
+// its lines, then indents it for the function it is wrapped in
+func dedent(body string) string {
+	body = strings.TrimRight(body, " \t\r\n")
+	lines := strings.Split(strings.TrimLeft(body, "\r\n"), "\n")
+	common, first := "", true
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		lines[i] = line
+		if line == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			common, first = indent, false
+		}
+		for !strings.HasPrefix(indent, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	var result bytes.Buffer
+	for _, line := range lines {
+		if line != "" {
+			result.WriteString("    " + strings.TrimPrefix(line, common))
+		}
+		result.WriteString("\n")
+	}
+	return result.String()
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
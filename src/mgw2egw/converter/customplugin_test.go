@@ -0,0 +1,214 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// convertPlugin writes index.js for a custom plugin and converts it,
+// returning the policies and the warnings
+func convertPlugin(t *testing.T, name string, index string) ([]Policy, string) {
+	dir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, name, "index.js"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var config mgconfig.Microgateway
+	config.Edgemicro.Plugin.Dir = dir
+	pluginConverter, ok := CustomPlugin(config, name)
+	if !ok {
+		t.Fatalf("no converter for the custom plugin %s", name)
+	}
+	var warnings bytes.Buffer
+	result, err := pluginConverter.Convert(Context{Config: config, ProxyName: "edgemicro_test", Warning: log.New(&warnings, "", 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, warnings.String()
+}
+
+func TestFindHandlersFunctionExpression(t *testing.T) {
+	handlers, err := findHandlers(`module.exports.init = function(config, logger, stats) {
+  return {
+    onrequest: function(req, res, next) {
+      req.headers['x-trace'] = 'on';
+      next();
+    }
+  };
+};`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handlers) != 1 {
+		t.Fatalf("found %d handlers, want 1", len(handlers))
+	}
+	if handlers[0].event != "onrequest" {
+		t.Errorf("event = %s, want onrequest", handlers[0].event)
+	}
+	if want := []string{"req", "res", "next"}; !reflect.DeepEqual(handlers[0].params, want) {
+		t.Errorf("params = %v, want %v", handlers[0].params, want)
+	}
+	if body := strings.TrimSpace(handlers[0].body); body != "req.headers['x-trace'] = 'on';\n      next();" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestFindHandlersArrowFunction(t *testing.T) {
+	handlers, err := findHandlers(`module.exports.init = (config, logger, stats) => ({
+  onresponse: (request, response, next) => {
+    response.setHeader('x-served-by', 'edge');
+    next();
+  },
+  ondata_response: async function transform(request, response, data, next) {
+    next(null, data);
+  }
+});`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handlers) != 2 {
+		t.Fatalf("found %d handlers, want 2", len(handlers))
+	}
+	if want := []string{"request", "response", "next"}; handlers[0].event != "onresponse" || !reflect.DeepEqual(handlers[0].params, want) {
+		t.Errorf("handler = %s%v, want onresponse%v", handlers[0].event, handlers[0].params, want)
+	}
+	if want := []string{"request", "response", "data", "next"}; handlers[1].event != "ondata_response" || !reflect.DeepEqual(handlers[1].params, want) {
+		t.Errorf("handler = %s%v, want ondata_response%v", handlers[1].event, handlers[1].params, want)
+	}
+}
+
+func TestFindHandlersBracesInStringsAndComments(t *testing.T) {
+	handlers, err := findHandlers(`module.exports.init = function(config, logger, stats) {
+  return {
+    onrequest: function(req, res, next) {
+      var closing = "}", opening = '{', template = ` + "`}${'}'}`" + `;
+      // an unbalanced } in a comment
+      /* and { in a block comment */
+      next();
+    },
+    onresponse: function(req, res, next) {
+      next();
+    }
+  };
+};`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handlers) != 2 {
+		t.Fatalf("found %d handlers, want 2", len(handlers))
+	}
+	body := strings.TrimSpace(handlers[0].body)
+	if !strings.HasPrefix(body, "var closing") || !strings.HasSuffix(body, "next();") {
+		t.Errorf("body of onrequest = %q", body)
+	}
+	if handlers[1].event != "onresponse" {
+		t.Errorf("second handler is %s, want onresponse", handlers[1].event)
+	}
+}
+
+func TestFindHandlersUnclosedBody(t *testing.T) {
+	if _, err := findHandlers(`onrequest: function(req, res, next) { next();`); err == nil {
+		t.Error("a handler without a closing brace was accepted")
+	}
+}
+
+func TestCustomPluginWithoutHandlers(t *testing.T) {
+	result, warnings := convertPlugin(t, "metrics", `module.exports.init = function(config, logger, stats) {
+  // "onrequest: function(req, res, next) {" is only mentioned here
+  return {};
+};`)
+	if len(result) != 0 {
+		t.Errorf("generated %d policies for a plugin without handlers", len(result))
+	}
+	if !strings.Contains(warnings, "no request or response handler found") {
+		t.Errorf("warnings = %q, want a warning about the missing handlers", warnings)
+	}
+}
+
+func TestCustomPluginScaffold(t *testing.T) {
+	result, warnings := convertPlugin(t, "add-trace", `var crypto = require('crypto');
+
+module.exports.init = function(config, logger, stats) {
+  return {
+    onrequest: function(req, res, next) {
+      req.headers['x-trace-id'] = crypto.randomBytes(8).toString('hex');
+      if (req.method === 'DELETE') {
+        res.statusCode = 405;
+      }
+      next();
+    },
+    onend_request: function(req, res, data, next) {
+      next(null, data);
+    },
+    onresponse: function(req, res, next) {
+      res.setHeader('x-trace-id', req.headers['x-trace-id']);
+      logger.info(req.socket.remoteAddress);
+      next();
+    }
+  };
+};`)
+	if len(result) != 2 {
+		t.Fatalf("generated %d policies, want 2", len(result))
+	}
+
+	request := string(findPolicy(t, result, "Javascript-add-trace-Request").Script)
+	for _, want := range []string{
+		"context.setVariable('request.header.x-trace-id', crypto.randomBytes(8).toString('hex'));",
+		"// TODO: crypto is a module loaded with require, which is not available in Edge",
+		"if (context.getVariable('request.verb') === 'DELETE') {",
+		"context.setVariable('response.status.code', 405);",
+		"// onend_request\n(function(data, next) {\n    // TODO: onend_request gets the last chunk of the body in microgateway, here data is the whole request content\n",
+		"})(context.getVariable('request.content'), nextHandler);",
+	} {
+		if !strings.Contains(request, want) {
+			t.Errorf("request script does not contain %q:\n%s", want, request)
+		}
+	}
+
+	response := findPolicy(t, result, "Javascript-add-trace-Response")
+	if response.Flow != PostFlow || !response.Response {
+		t.Errorf("response policy is in %s, response %v, want the PostFlow response", response.Flow, response.Response)
+	}
+	script := string(response.Script)
+	for _, want := range []string{
+		"context.setVariable('response.header.x-trace-id', context.getVariable('request.header.x-trace-id'));",
+		"// TODO: the microgateway logger and stats are not available, use print or a MessageLogging policy",
+		"// TODO: convert the use of req to the context object\n    logger.info(req.socket.remoteAddress);",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("response script does not contain %q:\n%s", want, script)
+		}
+	}
+
+	if !strings.Contains(warnings, "Javascript-add-trace-Request.js is a scaffold") || !strings.Contains(warnings, "review it and its 2 TODO markers") {
+		t.Errorf("warnings = %q", warnings)
+	}
+}
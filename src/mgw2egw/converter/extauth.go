@@ -0,0 +1,84 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"errors"
+	"fmt"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+)
+
+const (
+	extAuthJWTName          string = "Verify-JWT-ExtAuth-1"
+	extAuthAPIKeyName       string = "Verify-API-Key-ExtAuth-1"
+	extAuthRemoveHeaderName string = "Remove-Authorization-ExtAuth-1"
+	//extAuthClientId is the claim that holds the client id when client_id is not configured
+	extAuthClientId string = "client_id"
+)
+
+// extAuthConverter verifies JWTs issued by a third-party identity provider
+// against its JWKS, then verifies the client id claim as an API key
+type extAuthConverter struct{}
+
+func init() {
+	Register(extAuthConverter{})
+}
+
+func (extAuthConverter) Name() string {
+	return "extauth"
+}
+
+func (extAuthConverter) Section() string {
+	return "extauth"
+}
+
+func (extAuthConverter) Convert(context Context) ([]Policy, error) {
+	extAuth := mgconfig.GetExtAuthDetails(context.Config)
+	if extAuth.PublickeyUrl == "" {
+		return nil, errors.New("extauth.publickey_url is required")
+	}
+	clientId := extAuth.ClientId
+	if clientId == "" {
+		clientId = extAuthClientId
+	}
+	//microgateway rejects invalid tokens unless sendErr is false
+	sendErr := extAuth.SendErr == nil || *extAuth.SendErr
+	if extAuth.Exp != nil && !*extAuth.Exp {
+		context.warn(fmt.Sprintf("extauth: exp is false but %s always rejects expired tokens", extAuthJWTName))
+	}
+
+	verifyJWT := policies.NewVerifyJWTWithJWKS(extAuthJWTName, extAuth.PublickeyUrl).SetIssuer(extAuth.Iss)
+	verifyJWT.ContinueOnError = !sendErr
+	verifyAPIKey := policies.NewVerifyAPIKey(extAuthAPIKeyName, "jwt."+extAuthJWTName+".claim."+clientId)
+
+	result, err := newPolicies(namedPolicy{extAuthJWTName, verifyJWT}, namedPolicy{extAuthAPIKeyName, verifyAPIKey})
+	if err != nil {
+		return nil, err
+	}
+	if !sendErr {
+		result[1].Condition = "jwt." + extAuthJWTName + ".valid = true"
+	}
+
+	if !extAuth.KeepAuthHeader {
+		removeHeader := policies.NewAssignMessage(extAuthRemoveHeaderName, "request").RemoveHeader("Authorization")
+		policy, err := NewPolicy(extAuthRemoveHeaderName, removeHeader, TargetEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, policy)
+	}
+	return result, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"bytes"
+	"gopkg.in/yaml.v2"
+	"log"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"strings"
+	"testing"
+)
+
+func TestExtAuthExpWarning(t *testing.T) {
+	tests := []struct {
+		exp  string
+		warn bool
+	}{
+		{"", false},
+		{"  exp: true\n", false},
+		{"  exp: false\n", true},
+	}
+	for _, test := range tests {
+		var microgateway mgconfig.Microgateway
+		config := "extauth:\n  publickey_url: https://idp.example.com/jwks\n" + test.exp
+		if err := yaml.Unmarshal([]byte(config), &microgateway); err != nil {
+			t.Fatal(err)
+		}
+		var warnings bytes.Buffer
+		if _, err := (extAuthConverter{}).Convert(Context{Config: microgateway, Warning: log.New(&warnings, "", 0)}); err != nil {
+			t.Fatal(err)
+		}
+		if warned := strings.Contains(warnings.String(), "exp is false"); warned != test.warn {
+			t.Errorf("%q: warned = %v, want %v (warnings %q)", test.exp, warned, test.warn, warnings.String())
+		}
+	}
+}
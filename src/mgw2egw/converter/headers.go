@@ -0,0 +1,95 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+)
+
+const (
+	addForwardedForName   string = "Add-X-Forwarded-For-1"
+	addRequestHeadersName string = "Add-Request-Headers-1"
+	setResponseTimeName   string = "Set-Response-Time-1"
+)
+
+// responseTimeSource sets x-response-time to the milliseconds since the
+// request was received, AssignMessage cannot compute it
+const responseTimeSource string = `var received = context.getVariable('client.received.start.timestamp');
+context.setVariable('response.header.x-response-time', String(Date.now() - received));`
+
+// headersConverter adds the headers that microgateway sets on the requests
+// to the target and on the responses. Microgateway always applies the
+// headers section, so the conversion runs whether or not it is in the sequence
+type headersConverter struct{}
+
+func init() {
+	Register(headersConverter{})
+}
+
+func (headersConverter) Name() string {
+	return "headers"
+}
+
+func (headersConverter) Section() string {
+	return "headers"
+}
+
+func (headersConverter) Convert(context Context) ([]Policy, error) {
+	headers := mgconfig.GetHeaders(context.Config)
+	var result []Policy
+
+	//the Edge router adds the client address to x-forwarded-for, only requests
+	//that did not go through it need the header
+	if headers.XforwardedFor {
+		forwardedFor := policies.NewAssignMessage(addForwardedForName, "request").SetHeader("x-forwarded-for", "{client.ip}")
+		policy, err := NewPolicy(addForwardedForName, forwardedFor, ProxyEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		policy.Condition = "request.header.x-forwarded-for = null"
+		result = append(result, policy)
+	}
+
+	if headers.XForwardedHost || headers.XRequestId || headers.Via {
+		requestHeaders := policies.NewAssignMessage(addRequestHeadersName, "request")
+		if headers.XForwardedHost {
+			requestHeaders.SetHeader("x-forwarded-host", "{request.header.host}")
+		}
+		if headers.XRequestId {
+			requestHeaders.SetHeader("x-request-id", "{messageid}")
+		}
+		if headers.Via {
+			requestHeaders.SetHeader("via", "1.1 {system.uuid}")
+		}
+		policy, err := NewPolicy(addRequestHeadersName, requestHeaders, ProxyEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, policy)
+	}
+
+	if headers.XResponseTime {
+		policy, err := NewPolicy(setResponseTimeName, policies.NewJavascript(setResponseTimeName, responseTimeSource), ProxyEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		policy.Flow = PostFlow
+		policy.Response = true
+		result = append(result, policy)
+	}
+
+	return result, nil
+}
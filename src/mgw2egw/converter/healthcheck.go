@@ -0,0 +1,75 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"fmt"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+	"strconv"
+	"strings"
+)
+
+const (
+	healthcheckName     string = "Healthcheck-1"
+	healthcheckFlowName string = "Healthcheck"
+	//healthcheckUrl is the default of the microgateway healthcheck plugin
+	healthcheckUrl string = "/healthcheck"
+)
+
+// healthcheckConverter answers the health check url from the proxy with a
+// 200, without calling the target or running the plugins that come later
+type healthcheckConverter struct{}
+
+func init() {
+	Register(healthcheckConverter{})
+}
+
+func (healthcheckConverter) Name() string {
+	return "healthcheck"
+}
+
+func (healthcheckConverter) Section() string {
+	return "healthcheck"
+}
+
+func (healthcheckConverter) Convert(context Context) ([]Policy, error) {
+	assignMessage := policies.NewAssignMessage(healthcheckName, "response").
+		SetStatusCode(200, "OK").
+		SetJSONPayload(`{"status":"ok"}`)
+
+	policy, err := NewPolicy(healthcheckName, assignMessage, ProxyEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	policy.Flow = healthcheckFlowName
+	policy.Response = true
+	return []Policy{policy}, nil
+}
+
+func (healthcheckConverter) Flows(context Context) ([]ConditionalFlow, error) {
+	url := mgconfig.GetHealthcheckUrl(context.Config)
+	if url == "" {
+		url = healthcheckUrl
+	}
+	if !strings.HasPrefix(url, "/") {
+		return nil, fmt.Errorf("healthcheck.healthcheck_url must start with /, found %s", url)
+	}
+
+	//load balancers probe either the url itself or the url under the base path of the proxy
+	quoted := strconv.Quote(url)
+	condition := "(request.path = " + quoted + ") or (proxy.pathsuffix = " + quoted + ")"
+	return []ConditionalFlow{{Name: healthcheckFlowName, Condition: condition, NoRoute: true, Bypass: true}}, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"mgw2egw/policies"
+)
+
+const (
+	jsonToXMLName string = "JSON-to-XML-1"
+	xmlToJSONName string = "XML-to-JSON-1"
+	//jsonRequestCondition matches requests that send a JSON body
+	jsonRequestCondition string = `request.header.Content-Type ~ "*json*"`
+	//xmlResponseCondition matches XML responses to clients that accept JSON
+	xmlResponseCondition string = `response.header.Content-Type ~ "*xml*" and request.header.Accept ~ "*json*"`
+)
+
+// json2xmlConverter converts JSON request bodies to XML for the target, and
+// XML responses back to JSON for clients that accept it
+type json2xmlConverter struct{}
+
+func init() {
+	Register(json2xmlConverter{})
+}
+
+func (json2xmlConverter) Name() string {
+	return "json2xml"
+}
+
+func (json2xmlConverter) Section() string {
+	return "json2xml"
+}
+
+func (json2xmlConverter) Convert(context Context) ([]Policy, error) {
+	jsonToXML, err := NewPolicy(jsonToXMLName, policies.NewJSONToXML(jsonToXMLName, "request"), TargetEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	jsonToXML.Condition = jsonRequestCondition
+
+	xmlToJSON, err := NewPolicy(xmlToJSONName, policies.NewXMLToJSON(xmlToJSONName, "response"), TargetEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	xmlToJSON.Flow = PostFlow
+	xmlToJSON.Response = true
+	xmlToJSON.Condition = xmlResponseCondition
+
+	return []Policy{jsonToXML, xmlToJSON}, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"fmt"
+	"mgw2egw/policies"
+	"strconv"
+	"strings"
+)
+
+const (
+	lookupTokenCacheName    string = "Lookup-Token-Cache-1"
+	populateTokenCacheName  string = "Populate-Token-Cache-1"
+	assignClientIdName      string = "Assign-Client-Id-1"
+	removeAuthorizationName string = "Remove-Authorization-1"
+	//clientIdVariable holds the client id of a token, read from the cache or from the token
+	clientIdVariable string = "microgateway.client_id"
+	//tokenCacheTimeout is used when the time left before the token expires is unknown
+	tokenCacheTimeout int = 300
+)
+
+// jwtOptions are the settings shared by the plugins that verify the JWTs issued by microgateway
+type jwtOptions struct {
+	//jwks verifies the token against a JWKS instead of the public key in the
+	//microgateway KVM, either the url of the JWKS or the JWKS document itself
+	jwks         string
+	gracePeriod  string
+	tokenCache   bool
+	allowInvalid bool
+	//verifyClientId verifies the client_id claim of the token as an API key
+	verifyClientId bool
+}
+
+// jwtPolicies returns the policies that verify a JWT sent as a bearer token.
+// With the token cache, the client id of a verified token is cached until the
+// token expires, and the key lookup and the verification are skipped on a hit.
+func jwtPolicies(options jwtOptions) ([]Policy, error) {
+	allowance, err := timeAllowance(options.gracePeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	named := []namedPolicy{{extractVarName, policies.NewExtractVariables(extractVarName).AddHeader("Authorization", "Bearer {oauthtoken}")}}
+	if options.tokenCache {
+		named = append(named, namedPolicy{lookupTokenCacheName, policies.NewLookupCache(lookupTokenCacheName, "oauthtoken", clientIdVariable)})
+	}
+
+	var verifyJWT *policies.VerifyJWT
+	if jwks := strings.TrimSpace(options.jwks); strings.HasPrefix(jwks, "{") {
+		//a downloaded microgateway configuration caches the keys rather than their url
+		verifyJWT = policies.NewVerifyJWTWithKeySet(verifyJWTName, jwks)
+		verifyJWT.Source = "oauthtoken"
+	} else if jwks != "" {
+		verifyJWT = policies.NewVerifyJWTWithJWKS(verifyJWTName, jwks)
+		verifyJWT.Source = "oauthtoken"
+	} else {
+		kvm := policies.NewKeyValueMapOperations(kvmName, "microgateway").AddGet("private.publicKey", "public_key")
+		named = append(named, namedPolicy{kvmName, kvm})
+		verifyJWT = policies.NewVerifyJWT(verifyJWTName, "oauthtoken", "private.publicKey")
+	}
+	verifyJWT.AddClaim("audience", "microgateway").SetTimeAllowance(allowance)
+	verifyJWT.ContinueOnError = options.allowInvalid
+	named = append(named, namedPolicy{verifyJWTName, verifyJWT})
+
+	clientIdRef := "jwt." + verifyJWTName + ".claim.client_id"
+	if options.tokenCache {
+		assignClientId := policies.NewAssignMessage(assignClientIdName, "request").SetVariable(clientIdVariable, clientIdRef)
+		populateCache := policies.NewPopulateCache(populateTokenCacheName, "oauthtoken", clientIdVariable, "jwt."+verifyJWTName+".seconds_remaining", tokenCacheTimeout)
+		named = append(named, namedPolicy{assignClientIdName, assignClientId}, namedPolicy{populateTokenCacheName, populateCache})
+		clientIdRef = clientIdVariable
+	}
+
+	if options.verifyClientId {
+		verifyAPIKey := policies.NewVerifyAPIKey(verifyApiKeyName, clientIdRef)
+		verifyAPIKey.ContinueOnError = options.allowInvalid
+		named = append(named, namedPolicy{verifyApiKeyName, verifyAPIKey})
+	}
+
+	result, err := newPolicies(named...)
+	if err != nil {
+		return nil, err
+	}
+	if options.tokenCache {
+		miss := "lookupcache." + lookupTokenCacheName + ".cachehit = false"
+		for i, policy := range result {
+			switch policy.Name {
+			case kvmName, verifyJWTName:
+				result[i].Condition = miss
+			case assignClientIdName, populateTokenCacheName:
+				result[i].Condition = And(miss, "jwt."+verifyJWTName+".valid = true")
+			}
+		}
+	}
+	return result, nil
+}
+
+// timeAllowance converts the gracePeriod of microgateway, in seconds, to a VerifyJWT TimeAllowance
+func timeAllowance(gracePeriod string) (string, error) {
+	if gracePeriod == "" || gracePeriod == "0" {
+		return "", nil
+	}
+	seconds, err := strconv.Atoi(gracePeriod)
+	if err != nil || seconds < 0 {
+		return "", fmt.Errorf("gracePeriod must be a number of seconds, found %s", gracePeriod)
+	}
+	return strconv.Itoa(seconds) + "s", nil
+}
+
+// removeAuthorization removes the Authorization header before the request
+// is sent to the target, the way microgateway does unless
+// keep-authorization-header is set
+func removeAuthorization() (Policy, error) {
+	assignMessage := policies.NewAssignMessage(removeAuthorizationName, "request").RemoveHeader("Authorization")
+	return NewPolicy(removeAuthorizationName, assignMessage, TargetEndpoint)
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+)
+
+const oauthPolicyName string = "OAuth-v20-1"
+const verifyApiKeyName string = "Verify-API-Key-1"
+const extractVarName string = "Extract-Variables-1"
+const kvmName string = "Key-Value-Map-Operations-1"
+const verifyJWTName string = "Verify-JWT-1"
+
+// apiKeyHeader is the header microgateway reads the API key from by default
+const apiKeyHeader string = "x-api-key"
+
+type oauthConverter struct{}
+
+func init() {
+	Register(oauthConverter{})
+}
+
+func (oauthConverter) Name() string {
+	return "oauth"
+}
+
+func (oauthConverter) Section() string {
+	return "oauth"
+}
+
+func (oauthConverter) Convert(context Context) ([]Policy, error) {
+	oauth := mgconfig.GetOAuthDetails(context.Config)
+	var result []Policy
+	var err error
+	header := "Authorization"
+
+	if context.UseJWT {
+		result, err = jwtPolicies(jwtOptions{
+			gracePeriod:    oauth.GracePeriod,
+			tokenCache:     oauth.TokenCache,
+			allowInvalid:   oauth.AllowInvalidAuthorization,
+			verifyClientId: true,
+		})
+	} else if mgconfig.APIKeyOnly(context.Config) {
+		header = apiKeyHeaderName(oauth.ApiKeyHeader)
+		verifyAPIKey := policies.NewVerifyAPIKey(verifyApiKeyName, apiKeyRef(oauth.ApiKeyHeader))
+		verifyAPIKey.ContinueOnError = oauth.AllowInvalidAuthorization
+		result, err = newPolicies(namedPolicy{verifyApiKeyName, verifyAPIKey})
+	} else {
+		oauthV2 := policies.NewOAuthV2(oauthPolicyName)
+		oauthV2.ContinueOnError = oauth.AllowInvalidAuthorization
+		result, err = newPolicies(namedPolicy{oauthPolicyName, oauthV2})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if oauth.AllowNoAuthorization {
+		result = onlyWithHeader(result, header)
+	}
+	if !oauth.KeepAuthorizationHeader {
+		policy, err := removeAuthorization()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, policy)
+	}
+	return result, nil
+}
+
+// apiKeyPolicy returns the VerifyAPIKey policy that resolves the API product,
+// which changes the variables populated for it, or "" when an OAuthV2 policy does
+func apiKeyPolicy(context Context) string {
+	if hasPlugin(context.Config, "oauth") && (context.UseJWT || mgconfig.APIKeyOnly(context.Config)) {
+		return verifyApiKeyName
+	}
+	if hasPlugin(context.Config, "apikeys") {
+		return apiKeysPolicyName
+	}
+	if hasPlugin(context.Config, "extauth") {
+		return extAuthAPIKeyName
+	}
+	return ""
+}
+
+// clientIdRef returns the variable holding the client id of the calling app
+func clientIdRef(context Context) string {
+	if apiKey := apiKeyPolicy(context); apiKey != "" {
+		return "verifyapikey." + apiKey + ".client_id"
+	}
+	return "client_id"
+}
+
+// apiKeyHeaderName returns header, or x-api-key if header is ""
+func apiKeyHeaderName(header string) string {
+	if header == "" {
+		return apiKeyHeader
+	}
+	return header
+}
+
+// apiKeyRef returns the variable holding the API key sent in header, x-api-key if header is ""
+func apiKeyRef(header string) string {
+	return "request.header." + apiKeyHeaderName(header)
+}
+
+// onlyWithHeader skips the policies when the request does not send header,
+// the way microgateway lets such requests through with allowNoAuthorization
+func onlyWithHeader(result []Policy, header string) []Policy {
+	for i := range result {
+		result[i].Condition = And(result[i].Condition, "request.header."+header+" != null")
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	mgconfig "mgw2egw/microgatewayconfig"
+)
+
+// oauthV2Converter only accepts bearer JWTs, verified against the JWKS in
+// jwk_public_keys or against the public key microgateway keeps in the
+// microgateway KVM. Unlike oauth there is no API key fallback.
+type oauthV2Converter struct{}
+
+func init() {
+	Register(oauthV2Converter{})
+}
+
+func (oauthV2Converter) Name() string {
+	return "oauthv2"
+}
+
+func (oauthV2Converter) Section() string {
+	return "oauthv2"
+}
+
+func (oauthV2Converter) Convert(context Context) ([]Policy, error) {
+	oauthV2 := mgconfig.GetOAuthV2Details(context.Config)
+	result, err := jwtPolicies(jwtOptions{
+		jwks:         mgconfig.GetJwkPublicKeys(context.Config),
+		gracePeriod:  oauthV2.GracePeriod,
+		tokenCache:   oauthV2.TokenCache,
+		allowInvalid: oauthV2.AllowInvalidAuthorization,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if oauthV2.AllowNoAuthorization {
+		result = onlyWithHeader(result, "Authorization")
+	}
+	if !oauthV2.KeepAuthorizationHeader {
+		policy, err := removeAuthorization()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, policy)
+	}
+	return result, nil
+}
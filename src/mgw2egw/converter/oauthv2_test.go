@@ -0,0 +1,97 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"encoding/xml"
+	"gopkg.in/yaml.v2"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+	"testing"
+)
+
+// cachedJWKS is the jwk_public_keys of a configuration downloaded by microgateway
+const cachedJWKS = `{"keys":[{"kty":"RSA","e":"AQAB","use":"sig","kid":"1","alg":"RS256","n":"u1SU1LfVLPHCozMxH2Mo4lgOEePzNm0tRgeLezV6ffAt0gunVTLw7onLRnrq0_IzW7yWR7QkrmBL7jTKEn5u-qKhbwKfBstIs-bMY2Zkp18gnTxKLxoS2tFczGkPLPgizskuemMghRniWaoLcyehkd3qqGElvW_VDL5AaWTg0nLVkjRo9z-40RQzuVaE8AkAFmxZzow3x-VJYKdjykkJ0iT9wCS0DRTXu269V264Vf_3jvredZiKRkgwlL9xNAwxXFg0x_XFw005UWVRIkdgcKWTjpBP2dPwVZ4WWC-9aGVd-Gyn1o0CLelf4rEjGoXbAAEgAqeGUxrcIlbjXfbcmw"}]}`
+
+// convertConfig parses a microgateway configuration and converts it with the converter of plugin
+func convertConfig(t *testing.T, plugin string, config string) []Policy {
+	var microgateway mgconfig.Microgateway
+	if err := yaml.Unmarshal([]byte(config), &microgateway); err != nil {
+		t.Fatal(err)
+	}
+	pluginConverter, ok := Get(plugin)
+	if !ok {
+		t.Fatalf("no converter for %s", plugin)
+	}
+	result, err := pluginConverter.Convert(Context{Config: microgateway, ProxyName: "edgemicro_test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// findPolicy returns the policy named name
+func findPolicy(t *testing.T, result []Policy, name string) Policy {
+	for _, policy := range result {
+		if policy.Name == name {
+			return policy
+		}
+	}
+	t.Fatalf("no policy %s in %v", name, result)
+	return Policy{}
+}
+
+func TestOAuthV2CachedJWKS(t *testing.T) {
+	result := convertConfig(t, "oauthv2", `
+edge_config:
+  jwk_public_keys: '`+cachedJWKS+`'
+edgemicro:
+  plugins:
+    sequence:
+      - oauthv2
+`)
+	var verifyJWT policies.VerifyJWT
+	if err := xml.Unmarshal(findPolicy(t, result, verifyJWTName).Content, &verifyJWT); err != nil {
+		t.Fatal(err)
+	}
+	jwks := verifyJWT.PublicKey.JWKS
+	if jwks == nil {
+		t.Fatal("VerifyJWT has no JWKS")
+	}
+	if jwks.Uri != "" {
+		t.Errorf("JWKS uri = %q, want the keys inline", jwks.Uri)
+	}
+	if jwks.Value != cachedJWKS {
+		t.Errorf("JWKS = %q, want %q", jwks.Value, cachedJWKS)
+	}
+}
+
+func TestOAuthV2JWKSUri(t *testing.T) {
+	result := convertConfig(t, "oauthv2", `
+oauthv2:
+  jwk_public_keys: https://trial-test.apigee.net/edgemicro-auth/jwkPublicKeys
+edgemicro:
+  plugins:
+    sequence:
+      - oauthv2
+`)
+	var verifyJWT policies.VerifyJWT
+	if err := xml.Unmarshal(findPolicy(t, result, verifyJWTName).Content, &verifyJWT); err != nil {
+		t.Fatal(err)
+	}
+	if jwks := verifyJWT.PublicKey.JWKS; jwks == nil || jwks.Uri != "https://trial-test.apigee.net/edgemicro-auth/jwkPublicKeys" || jwks.Value != "" {
+		t.Errorf("JWKS = %+v, want the url in uri", jwks)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"fmt"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const quotaPolicyName string = "Quota-1"
+
+const (
+	quotaIdentifierName string = "Assign-Quota-Identifier-1"
+	//quotaIdentifierVariable keeps a counter per client and API product
+	quotaIdentifierVariable string = "microgateway.quota_identifier"
+)
+
+// policyNameChars are the characters that cannot be used in a policy name
+var policyNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+type quotaConverter struct{}
+
+func init() {
+	Register(quotaConverter{})
+}
+
+func (quotaConverter) Name() string {
+	return "quota"
+}
+
+func (quotaConverter) Section() string {
+	return "quotas"
+}
+
+// Convert returns a single Quota policy with the limits of the API product, or
+// one Quota policy per product listed in the quotas section, each running
+// only for requests resolved to that product
+func (quotaConverter) Convert(context Context) ([]Policy, error) {
+	apiKey := apiKeyPolicy(context)
+	quotas := mgconfig.GetQuotas(context.Config)
+	if len(quotas.Products) == 0 {
+		return newPolicies(namedPolicy{quotaPolicyName, policies.NewQuota(quotaPolicyName, apiKey)})
+	}
+
+	identifier := policies.NewAssignMessage(quotaIdentifierName, "request").SetVariableTemplate(quotaIdentifierVariable, "{"+clientIdRef(context)+"}-{apiproduct.name}")
+	named := []namedPolicy{{quotaIdentifierName, identifier}}
+
+	if quotas.FailOpen {
+		context.warn("quotas: Edge has no equivalent of failOpen, requests are rejected when the quota cannot be checked")
+	}
+
+	for _, productQuota := range quotas.Products {
+		name := "Quota-" + policyNameChars.ReplaceAllString(productQuota.Product, "-")
+		productQuota.UseRedis = productQuota.UseRedis || quotas.UseRedis
+		productQuota.BufferSize = quotas.ProductBufferSize(productQuota)
+		quota, err := productQuotaPolicy(name, apiKey, productQuota)
+		if err != nil {
+			return nil, err
+		}
+		named = append(named, namedPolicy{name, quota})
+	}
+
+	result, err := newPolicies(named...)
+	if err != nil {
+		return nil, err
+	}
+	for i, productQuota := range quotas.Products {
+		result[i+1].Condition = "apiproduct.name = " + strconv.Quote(productQuota.Product)
+	}
+	return result, nil
+}
+
+// productQuotaPolicy returns the Quota policy of an API product. The limits
+// set in the quotas section replace the ones of the product, and the shared
+// settings are already applied to productQuota.
+func productQuotaPolicy(name string, apiKey string, productQuota mgconfig.ProductQuota) (*policies.Quota, error) {
+	quota := policies.NewQuota(name, apiKey).SetIdentifier(quotaIdentifierVariable)
+
+	switch strings.ToLower(productQuota.QuotaType) {
+	case "", "calendar":
+	case "rollingwindow":
+		quota.Type = "rollingwindow"
+	case "flexi":
+		quota.Type = "flexi"
+	default:
+		return nil, fmt.Errorf("quotas.%s.quotaType must be calendar, rollingwindow or flexi, found %s", productQuota.Product, productQuota.QuotaType)
+	}
+
+	if productQuota.Allow > 0 {
+		quota.Allow = policies.QuotaAllow{Count: productQuota.Allow}
+	}
+	if productQuota.Interval > 0 {
+		quota.Interval = policies.Ref{Value: strconv.Itoa(productQuota.Interval)}
+	} else if productQuota.Allow > 0 && productQuota.TimeUnit != "" {
+		//like microgateway, a quota with its own limits counts over one time unit
+		quota.Interval = policies.Ref{Value: "1"}
+	}
+	switch productQuota.TimeUnit {
+	case "":
+	case "minute", "hour", "day", "week", "month":
+		quota.TimeUnit = policies.Ref{Value: productQuota.TimeUnit}
+	default:
+		return nil, fmt.Errorf("quotas.%s.timeUnit must be minute, hour, day, week or month, found %s", productQuota.Product, productQuota.TimeUnit)
+	}
+
+	//with redis every instance shares the counter, otherwise microgateway
+	//buffers bufferSize requests before syncing it
+	if !productQuota.UseRedis && productQuota.BufferSize > 0 {
+		quota.SetAsynchronous(productQuota.BufferSize)
+	}
+	return quota, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"encoding/xml"
+	"mgw2egw/policies"
+	"testing"
+)
+
+// quotaPolicy returns the Quota policy named name
+func quotaPolicy(t *testing.T, result []Policy, name string) policies.Quota {
+	var quota policies.Quota
+	if err := xml.Unmarshal(findPolicy(t, result, name).Content, &quota); err != nil {
+		t.Fatal(err)
+	}
+	return quota
+}
+
+func TestQuotaSectionSettings(t *testing.T) {
+	result := convertConfig(t, "quota", `
+edgemicro:
+  plugins:
+    sequence:
+      - quota
+quotas:
+  bufferSize:
+    hour: 20000
+    minute: 500
+    default: 10000
+  useDebugMpId: true
+  failOpen: true
+  isHTTPStatusTooManyRequestEnabled: true
+  Gold-Product:
+    allow: 1000
+    timeUnit: hour
+  Silver-Product:
+    allow: 100
+    timeUnit: day
+  Bronze-Product:
+    allow: 10
+    timeUnit: minute
+    bufferSize: 5
+`)
+	for name, want := range map[string]int{"Quota-Gold-Product": 20000, "Quota-Silver-Product": 10000, "Quota-Bronze-Product": 5} {
+		quota := quotaPolicy(t, result, name)
+		if quota.Synchronous || quota.AsynchronousConfiguration == nil || quota.AsynchronousConfiguration.SyncMessageCount != want {
+			t.Errorf("%s syncs with %+v, want every %d requests", name, quota.AsynchronousConfiguration, want)
+		}
+		if !quota.Distributed {
+			t.Errorf("%s is not distributed", name)
+		}
+	}
+}
+
+func TestQuotaSectionUseRedis(t *testing.T) {
+	result := convertConfig(t, "quota", `
+edgemicro:
+  plugins:
+    sequence:
+      - quota
+quotas:
+  bufferSize:
+    default: 10000
+  useRedis: true
+  Gold-Product:
+    allow: 1000
+    timeUnit: hour
+`)
+	quota := quotaPolicy(t, result, "Quota-Gold-Product")
+	if !quota.Distributed || !quota.Synchronous || quota.AsynchronousConfiguration != nil {
+		t.Errorf("Quota-Gold-Product is not distributed and synchronous with useRedis: %+v", quota)
+	}
+}
+
+func TestQuotaWithoutSettings(t *testing.T) {
+	result := convertConfig(t, "quota", `
+edgemicro:
+  plugins:
+    sequence:
+      - quota
+quotas:
+  Gold-Product:
+    allow: 1000
+    timeUnit: hour
+`)
+	quota := quotaPolicy(t, result, "Quota-Gold-Product")
+	if !quota.Synchronous || quota.AsynchronousConfiguration != nil {
+		t.Errorf("Quota-Gold-Product is not synchronous without a bufferSize: %+v", quota)
+	}
+}
+
+func TestQuotaWithoutInterval(t *testing.T) {
+	result := convertConfig(t, "quota", `
+edgemicro:
+  plugins:
+    sequence:
+      - quota
+quotas:
+  Gold-Product:
+    allow: 1000
+    timeUnit: hour
+  Silver-Product:
+    allow: 100
+    interval: 2
+    timeUnit: day
+`)
+	gold := quotaPolicy(t, result, "Quota-Gold-Product")
+	if gold.Interval.Ref != "" || gold.Interval.Value != "1" {
+		t.Errorf("Quota-Gold-Product interval = %+v, want 1 without a ref", gold.Interval)
+	}
+	if gold.Allow.Count != 1000 || gold.Allow.CountRef != "" || gold.TimeUnit.Value != "hour" || gold.TimeUnit.Ref != "" {
+		t.Errorf("Quota-Gold-Product = %+v %+v, want 1000 per hour", gold.Allow, gold.TimeUnit)
+	}
+	silver := quotaPolicy(t, result, "Quota-Silver-Product")
+	if silver.Interval.Ref != "" || silver.Interval.Value != "2" {
+		t.Errorf("Quota-Silver-Product interval = %+v, want 2", silver.Interval)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"mgw2egw/policies"
+)
+
+const quotaMemoryName string = "Quota-Memory-1"
+
+// quotaMemoryConverter keeps the in-memory counters of the quota-memory
+// plugin as a quota that is not distributed, with the limits of the API product
+type quotaMemoryConverter struct{}
+
+func init() {
+	Register(quotaMemoryConverter{})
+}
+
+func (quotaMemoryConverter) Name() string {
+	return "quota-memory"
+}
+
+func (quotaMemoryConverter) Section() string {
+	return "quota-memory"
+}
+
+func (quotaMemoryConverter) Convert(context Context) ([]Policy, error) {
+	context.warn("quota-memory: " + quotaMemoryName + " counts per Edge message processor, not per microgateway instance, so the number of requests allowed depends on how many message processors serve the proxy")
+	quota := policies.NewQuota(quotaMemoryName, apiKeyPolicy(context)).SetLocal()
+	return newPolicies(namedPolicy{quotaMemoryName, quota})
+}
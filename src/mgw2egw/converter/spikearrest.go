@@ -0,0 +1,74 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"fmt"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
+	"strconv"
+)
+
+const spikeArrestName string = "Spike-Arrest-1"
+
+// spikeArrestRates maps the microgateway time units to the suffix of the Edge rate
+var spikeArrestRates = map[string]string{
+	"second": "ps",
+	"minute": "pm",
+}
+
+type spikeArrestConverter struct{}
+
+func init() {
+	Register(spikeArrestConverter{})
+}
+
+func (spikeArrestConverter) Name() string {
+	return "spikearrest"
+}
+
+func (spikeArrestConverter) Section() string {
+	return "spikearrest"
+}
+
+// Convert returns a SpikeArrest policy with the rate of the spikearrest
+// section. Edge does not queue requests, so a bufferSize smooths the rate
+// with the effective count of the message processors instead
+func (spikeArrestConverter) Convert(context Context) ([]Policy, error) {
+	spikeArrest := mgconfig.GetSpikeArrestDetails(context.Config)
+
+	if spikeArrest.Allow <= 0 {
+		return nil, fmt.Errorf("spikearrest.allow must be greater than 0, found %d", spikeArrest.Allow)
+	}
+	suffix, ok := spikeArrestRates[spikeArrest.TimeUnit]
+	if !ok {
+		return nil, fmt.Errorf("spikearrest.timeUnit must be second or minute, found %q", spikeArrest.TimeUnit)
+	}
+
+	policy := policies.NewSpikeArrest(spikeArrestName, strconv.Itoa(spikeArrest.Allow)+suffix)
+	switch spikeArrest.Identifier {
+	case "":
+	case "client_id":
+		policy.SetIdentifier(clientIdRef(context))
+	default:
+		policy.SetIdentifier(spikeArrest.Identifier)
+	}
+	if spikeArrest.Buffersize > 0 {
+		policy.UseEffectiveCount = true
+		context.warn(fmt.Sprintf("spikearrest: Edge does not queue requests, bufferSize %d is converted to UseEffectiveCount", spikeArrest.Buffersize))
+	}
+
+	return newPolicies(namedPolicy{spikeArrestName, policy})
+}
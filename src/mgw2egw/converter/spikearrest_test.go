@@ -0,0 +1,60 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"mgw2egw/policies"
+	"testing"
+)
+
+// spikeArrestRate returns the rate of the SpikeArrest policy of a conversion
+func spikeArrestRate(t *testing.T, result []Policy) string {
+	var spikeArrest policies.SpikeArrest
+	if err := xml.Unmarshal(findPolicy(t, result, spikeArrestName).Content, &spikeArrest); err != nil {
+		t.Fatal(err)
+	}
+	return spikeArrest.Rate
+}
+
+// TestSpikeArrestConfigsAreIndependent converts two configurations in the
+// same process, the policy of one must not carry the rate of the other
+func TestSpikeArrestConfigsAreIndependent(t *testing.T) {
+	perSecond := `
+spikearrest:
+  timeUnit: second
+  allow: 10
+`
+	perMinute := `
+spikearrest:
+  timeUnit: minute
+  allow: 300
+`
+	first := convertConfig(t, "spikearrest", perSecond)
+	second := convertConfig(t, "spikearrest", perMinute)
+
+	if rate := spikeArrestRate(t, first); rate != "10ps" {
+		t.Errorf("rate of the first configuration = %s, want 10ps", rate)
+	}
+	if rate := spikeArrestRate(t, second); rate != "300pm" {
+		t.Errorf("rate of the second configuration = %s, want 300pm", rate)
+	}
+
+	again := convertConfig(t, "spikearrest", perSecond)
+	if !bytes.Equal(again[0].Content, first[0].Content) {
+		t.Errorf("converting the first configuration again gives\n%s\nwant\n%s", again[0].Content, first[0].Content)
+	}
+}
@@ -15,20 +15,28 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	apigee "github.com/srinandan/go-apigee-edge"
 	"io"
 	"io/ioutil"
 	"log"
+	"mgw2egw/converter"
 	mgconfig "mgw2egw/microgatewayconfig"
+	"mgw2egw/policies"
 	proxyutils "mgw2egw/proxyutils"
 	utils "mgw2egw/utils"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
-var org, env, username, password, configFile, fldr string
+var org, env, username, password, configFile, fldr, bundle, templatesFolder, reportFile string
+
+var policyTemplates *policies.Templates
 
 var (
 	infoLogger   bool
@@ -36,17 +44,13 @@ var (
 	importOnly   bool
 	genOnly      bool
 	useJwt       bool
+	plan         bool
+	keepGoing    bool
+	replace      bool
 )
 
 const version string = "1.0.0"
 const proxyprefix string = "edgemicro_"
-const oauthPolicyName string = "OAuth-v20-1"
-const quotaPolicyName string = "Quota-1"
-const verifyApiKeyName string = "Verify-API-Key-1"
-const spikeArrestName string = "Spike-Arrest-1"
-const extractVarName string = "Extract-Variables-1"
-const kvmName string = "Key-Value-Map-Operations-1"
-const verifyJWTName string = "Verify-JWT-1"
 
 var (
 	Info    *log.Logger
@@ -72,7 +76,14 @@ func Init(
 		log.Ldate|log.Ltime|log.Lshortfile)
 }
 
-func checkParams(org, env, username, password, configFile string) {
+func checkParams(org, env, username, password, configFile, bundle string) {
+	if bundle != "" {
+		if configFile == "" {
+			usage("configFile cannot be empty")
+		}
+		return
+	}
+
 	if org == "" {
 		usage("orgname cannot be empty")
 	} else if env == "" {
@@ -99,10 +110,18 @@ func main() {
 	flag.BoolVar(&importOnly, "importonly", false, "Import the proxies only, do not deploy")
 	flag.BoolVar(&genOnly, "genonly", false, "Generate the bundles only, do not import")
 	flag.BoolVar(&useJwt, "usejwt", false, "Use JWT Policies to validate OAuth tokens")
+	flag.StringVar(&templatesFolder, "templates", "", "Folder with policy templates that replace the built-in policies")
+	flag.BoolVar(&keepGoing, "keepgoing", false, "Continue with the next proxy when a proxy fails")
+	flag.BoolVar(&replace, "replace", false, "Replace the policies of a bundle that have the name of a generated policy")
+	flag.BoolVar(&plan, "plan", false, "Show the changes for every proxy without importing or deploying")
+	flag.StringVar(&bundle, "bundle", "", "Convert a local proxy bundle (zip file or folder) without connecting to Apigee Edge")
+
+	flag.StringVar(&reportFile, "report", "", "Write a JSON report of the run to this file")
 
 	flag.Parse()
+	runStarted := time.Now()
 
-	checkParams(org, env, username, password, configFile)
+	checkParams(org, env, username, password, configFile, bundle)
 
 	if infoLogger {
 		Init(os.Stdout, os.Stdout, os.Stderr)
@@ -117,13 +136,32 @@ func main() {
 		return
 	}
 
+	if templatesFolder != "" {
+		Info.Println("Reading policy templates from ", templatesFolder)
+		policyTemplates, err = policies.LoadTemplates(templatesFolder)
+		if err != nil {
+			Error.Fatalln("Unable to parse policy templates: ", err)
+			return
+		}
+		Info.Println("Found policy templates: ", policyTemplates.Names())
+	}
+
+	if bundle != "" {
+		Info.Println("Converting local bundle ", bundle)
+		started := time.Now()
+		result := ConvertBundle(bundle, config)
+		result.Started = started
+		result.DurationMs = time.Since(started).Nanoseconds() / int64(time.Millisecond)
+		finish(runStarted, config, []ProxyResult{result})
+	}
+
 	auth := apigee.EdgeAuth{Username: username, Password: password}
 	opts := &apigee.EdgeClientOptions{Org: org, Auth: &auth, Debug: clientLogger}
 	Info.Println("Initializing Apigee Edge client...")
 	client, err := apigee.NewEdgeClient(opts)
 
 	if err != nil {
-		Error.Fatalln("Error initializing Edge client:\n%#v\n", err)
+		Error.Fatalf("Error initializing Edge client:\n%#v\n", err)
 		return
 	}
 	Info.Println("Initialization successful!")
@@ -131,7 +169,7 @@ func main() {
 	edgemicroproxies, err := GetEdgemicroProxies(client)
 
 	if err != nil {
-		Error.Fatalln("Error downloading proxies:\n%#v\n", err)
+		Error.Fatalf("Error downloading proxies:\n%#v\n", err)
 		return
 	}
 
@@ -142,126 +180,595 @@ func main() {
 		Info.Println("Found Edgemicro proxies: ", edgemicroproxies)
 	}
 
-	for _, edgemicroproxy := range edgemicroproxies {
-		if mgconfig.IsProxySet(edgemicroproxy, config) {
-			Info.Println("Changing Proxy: ", edgemicroproxy)
-			revision, err := GetLatestRevision(edgemicroproxy, client)
-			if err != nil {
-				return
+	var results []ProxyResult
+	for i, edgemicroproxy := range edgemicroproxies {
+		started := time.Now()
+		result := ConvertProxy(edgemicroproxy, config, client)
+		result.Started = started
+		result.DurationMs = time.Since(started).Nanoseconds() / int64(time.Millisecond)
+		results = append(results, result)
+		if result.Status == Failed && !keepGoing {
+			Warning.Println("Stopping after the failure of ", edgemicroproxy, ", use -keepgoing to convert the remaining proxies")
+			//list the remaining proxies so the summary and the report cover all of them
+			for _, remaining := range edgemicroproxies[i+1:] {
+				results = append(results, ProxyResult{Proxy: remaining}.skip("not attempted after earlier failure"))
 			}
-			Info.Println("Latest proxy revision is: ", revision)
+			break
+		}
+	}
 
-			bundleName, err := DownloadProxy(edgemicroproxy, revision, client)
-			if err != nil {
-				return
-			}
-			Info.Println("Downloaded bundle: ", bundleName)
-			Info.Println("Extracting bundle...")
-			ExtractBundle(bundleName)
+	finish(runStarted, config, results)
+}
 
-			err = AddPolicies(edgemicroproxy, bundleName, config)
-			if err != nil {
-				return
-			}
+// finish prints the summary, writes the report and exits. The exit code is
+// computed once, so the report records the code the process exits with.
+func finish(runStarted time.Time, config mgconfig.Microgateway, results []ProxyResult) {
+	printSummary(results)
 
-			importtedRevision, err := ImportProxy(edgemicroproxy, bundleName, client)
-			if err != nil {
-				return
-			}
+	code := exitCode(results)
+	if err := checkUnusedTemplates(); err != nil {
+		Error.Println(err)
+		code = exitFailed
+	}
 
-			if !importOnly {
-				Info.Println("Deploying proxy ", edgemicroproxy, " with revision ", importtedRevision, " to ", env)
-				DeployProxy(edgemicroproxy, env, revision, importtedRevision, client)
-			} else {
-				Info.Println("Importing proxy ", edgemicroproxy, " with revision ", importtedRevision)
-			}
+	if reportFile != "" {
+		Info.Println("Writing report to ", reportFile)
+		if err := writeReport(reportFile, runStarted, config, results, code); err != nil {
+			Error.Println("Error writing report: ", err)
+		}
+	}
+	os.Exit(code)
+}
+
+// checkUnusedTemplates reports the templates that matched no generated policy.
+// With -plan they are an error, otherwise a warning.
+func checkUnusedTemplates() error {
+	unused := policyTemplates.Unused()
+	if len(unused) == 0 {
+		return nil
+	}
+	if plan {
+		return fmt.Errorf("policy templates %v match no generated policy", unused)
+	}
+	Warning.Println("Policy templates ", unused, " match no generated policy and were not used")
+	return nil
+}
+
+// ConvertProxy downloads the latest revision of a proxy, converts it, then
+// imports and deploys it according to the command line options
+func ConvertProxy(proxyName string, config mgconfig.Microgateway, client *apigee.EdgeClient) ProxyResult {
+
+	result := ProxyResult{Proxy: proxyName}
 
+	if !mgconfig.IsProxySet(proxyName, config) {
+		Info.Println("Skipping Proxy: ", proxyName)
+		return result.skip("not listed in the Microgateway configuration")
+	}
+
+	Info.Println("Changing Proxy: ", proxyName)
+	revision, err := GetLatestRevision(proxyName, client)
+	if err != nil {
+		return result.fail("Error getting revision", err)
+	}
+	Info.Println("Latest proxy revision is: ", revision)
+	result.SourceRevision = revision
+
+	bundleName, err := DownloadProxy(proxyName, revision, client)
+	if err != nil {
+		return result.fail("Error downloading proxy", err)
+	}
+	Info.Println("Downloaded bundle: ", bundleName)
+
+	keepBundle := false
+	defer func() {
+		if !keepBundle {
 			Info.Println("Cleaning up ", bundleName)
-			utils.Cleanup(bundleName, genOnly)
-		} else {
-			Info.Println("Skipping Proxy: ", edgemicroproxy)
+			utils.Cleanup(bundleName, false)
 		}
+	}()
+
+	Info.Println("Extracting bundle...")
+	if err = ExtractBundle(bundleName); err != nil {
+		return result.fail("Error extracting bundle", err)
 	}
+	bundleFolder := strings.Split(bundleName, ".")[0]
+
+	before, err := snapshotBundle(bundleFolder)
+	if err != nil {
+		return result.fail("Error reading bundle", err)
+	}
+
+	conversion, err := AddPolicies(proxyName, bundleFolder, config)
+	if err != nil {
+		return result.fail("Error adding policies", err)
+	}
+	result.Plugins = conversion.Plugins
+	result.Unconverted = conversion.Unconverted
+	result.Scaffolded = conversion.Scaffolded
+	result.Policies = conversion.Policies
+
+	if plan {
+		calls := plannedCalls(proxyName, revision, bundleFolder)
+		if err = printPlan(proxyName, fmt.Sprintf("revision %d", revision), conversion, calls, bundleFolder, before); err != nil {
+			return result.fail("Error printing plan", err)
+		}
+		if conversion.Unchanged {
+			return result.skip("already converted")
+		}
+		result.Status = Planned
+		return result
+	}
+
+	if conversion.Unchanged {
+		Info.Println("Skipping Proxy ", proxyName, ", revision ", revision, " is already converted")
+		return result.skip("already converted")
+	}
+
+	if genOnly {
+		Info.Println("Converted bundle written to ", bundleFolder)
+		keepBundle = true
+		result.Status = Converted
+		result.Reason = "bundle written to " + bundleFolder
+		return result
+	}
+
+	importedRevision, err := ImportProxy(proxyName, bundleName, client)
+	if err != nil {
+		return result.fail("Error importing proxy", err)
+	}
+	Info.Println("Imported proxy ", proxyName, " with revision ", importedRevision)
+	result.NewRevision = importedRevision
+	result.Status = Imported
+
+	if importOnly {
+		return result
+	}
+
+	Info.Println("Deploying proxy ", proxyName, " with revision ", importedRevision, " to ", env)
+	if err = DeployProxy(proxyName, env, revision, importedRevision, client); err != nil {
+		result.DeployResult = "failed"
+		return result.fail("Error deploying proxy", err)
+	}
+	result.DeployResult = "deployed to " + env
+	result.Status = Deployed
+	return result
 }
 
-func AddPolicies(proxyName string, bundleName string, config mgconfig.Microgateway) error {
+// plannedCalls describes what the run would do with a converted proxy, from
+// the same options as the run itself
+func plannedCalls(proxyName string, revision apigee.Revision, bundleFolder string) []string {
+	if genOnly {
+		return []string{"Write the converted bundle to " + bundleFolder + ", nothing is imported"}
+	}
+	calls := []string{"Import the converted bundle as a new revision of " + proxyName}
+	if !importOnly {
+		calls = append(calls, fmt.Sprintf("Undeploy revision %d from %s", revision, env),
+			"Deploy the new revision to "+env)
+	}
+	return calls
+}
+
+// Conversion describes the changes AddPolicies made to a bundle
+type Conversion struct {
+	//Plugins are the plugins that were converted, Unconverted the plugins
+	//without a converter and Scaffolded the custom plugins converted to a
+	//Javascript scaffold, which are in Plugins too
+	Plugins         []string
+	Unconverted     []string
+	Scaffolded      []string
+	Policies        []string
+	Flows           []converter.ConditionalFlow
+	ProxyEndpoints  []string
+	ProxySteps      []proxyutils.Step
+	TargetEndpoints []string
+	TargetSteps     []proxyutils.Step
+	//Unchanged is set when the bundle was already converted with the same result
+	Unchanged bool
+}
+
+func AddPolicies(proxyName string, bundleFolder string, config mgconfig.Microgateway) (Conversion, error) {
 
 	Info.Println("Adding Edge policies to proxy...")
 	plugins := mgconfig.GetPlugins(config)
-	bundlePart := strings.Split(bundleName, ".")[0]
-	policiesFolder := bundlePart + "/apiproxy/policies"
-	apiProxyXMLFile := bundlePart + "/apiproxy/" + proxyName + ".xml"
-	proxyEndpointXMLFile := bundlePart + "/apiproxy/proxies/default.xml"
-	oauth := true
+	//microgateway adds the headers ahead of the plugins, whatever the sequence
+	if mgconfig.AddsHeaders(config) && !contains(plugins, "headers") {
+		plugins = append([]string{"headers"}, plugins...)
+	}
+	policiesFolder := bundleFolder + "/apiproxy/policies"
+	proxiesFolder := bundleFolder + "/apiproxy/proxies"
+	targetsFolder := bundleFolder + "/apiproxy/targets"
+	scriptsFolder := bundleFolder + "/apiproxy/resources/jsc"
+	apiProxyXMLFile := bundleFolder + "/apiproxy/" + proxyName + ".xml"
+	context := converter.Context{Config: config, ProxyName: proxyName, UseJWT: useJwt, Warning: Warning}
+
+	//steps attached to the ProxyEndpoints (northbound) and TargetEndpoints
+	//(southbound), kept per plugin until they are placed in the flows
+	var proxyPluginSteps, targetPluginSteps [][]proxyutils.Step
+	var generated []converter.Policy
+	var flows []converter.ConditionalFlow
+	var converted, unconverted, scaffolded []string
+	//bypass holds the conditions of the flows that skip the plugins later in the sequence
+	var bypass []string
 
 	apiProxy, err := proxyutils.ReadAPIProxy(apiProxyXMLFile)
 	if err != nil {
-		Error.Fatalln("Error reading APIProxy file:\n%#v\n", err)
-		return err
+		return Conversion{}, err
 	}
 
-	proxyEndpoint, err := proxyutils.ReadProxyEndpoint(proxyEndpointXMLFile)
-	if err != nil {
-		Error.Fatalln("Error reading ProxyEndpoint file:\n%#v\n", err)
-		return err
+	for _, plugin := range plugins {
+		pluginConverter, ok := converter.Get(plugin)
+		if !ok {
+			//a custom plugin in edgemicro.plugins.dir becomes a Javascript scaffold
+			if pluginConverter, ok = converter.CustomPlugin(config, plugin); ok {
+				scaffolded = append(scaffolded, plugin)
+			}
+		}
+		if !ok {
+			Warning.Println("No converter found for plugin ", plugin, ", it has to be reimplemented manually")
+			unconverted = append(unconverted, plugin)
+			continue
+		}
+		converted = append(converted, plugin)
+
+		Info.Println("Converting plugin ", plugin)
+		pluginPolicies, err := pluginConverter.Convert(context)
+		if err != nil {
+			return Conversion{}, fmt.Errorf("converting plugin %s: %v", plugin, err)
+		}
+		var pluginFlows []converter.ConditionalFlow
+		if flowConverter, ok := pluginConverter.(converter.FlowConverter); ok {
+			pluginFlows, err = flowConverter.Flows(context)
+			if err != nil {
+				return Conversion{}, fmt.Errorf("converting plugin %s: %v", plugin, err)
+			}
+		}
+
+		var pluginProxySteps, pluginTargetSteps []proxyutils.Step
+		for _, policy := range pluginPolicies {
+			for _, other := range generated {
+				if other.Name == policy.Name {
+					return Conversion{}, fmt.Errorf("converting plugin %s: policy %s is also generated by an earlier plugin", plugin, policy.Name)
+				}
+			}
+			content, ok, err := policyTemplates.Render(policy.Name, policies.TemplateData{Config: config, ProxyName: proxyName})
+			if err != nil {
+				return Conversion{}, err
+			}
+			if ok {
+				Info.Println("Using template for policy ", policy.Name)
+				policy.Content = content
+			}
+			generated = append(generated, policy)
+
+			step := proxyutils.Step{Policy: policy.Name, Flow: policy.Flow, Response: policy.Response, Condition: policy.Condition, First: policy.First}
+			var faultRuleSteps []proxyutils.Step
+			if policy.FaultRule {
+				faultRuleSteps = append(faultRuleSteps, proxyutils.Step{Policy: policy.Name, Flow: proxyutils.DefaultFaultRule})
+			}
+			if policy.Endpoint == converter.TargetEndpoint {
+				pluginTargetSteps = append(append(pluginTargetSteps, step), faultRuleSteps...)
+				continue
+			}
+			if !step.Response && (step.Flow == "" || step.Flow == converter.PreFlow || step.Flow == converter.PostFlow) {
+				for _, condition := range bypass {
+					step.Condition = converter.And(step.Condition, converter.Not(condition))
+				}
+			}
+			pluginProxySteps = append(append(pluginProxySteps, step), faultRuleSteps...)
+		}
+		proxyPluginSteps = append(proxyPluginSteps, pluginProxySteps)
+		targetPluginSteps = append(targetPluginSteps, pluginTargetSteps)
+
+		for _, flow := range pluginFlows {
+			if flow.Bypass {
+				bypass = append(bypass, flow.Condition)
+			}
+		}
+		flows = append(flows, pluginFlows...)
+	}
+
+	//requests go through the plugins in the order of the sequence and
+	//responses in the reverse order
+	proxySteps := proxyutils.PlaceSteps(proxyPluginSteps...)
+	targetSteps := proxyutils.PlaceSteps(targetPluginSteps...)
+
+	conversion := Conversion{
+		Plugins:         converted,
+		Unconverted:     unconverted,
+		Scaffolded:      scaffolded,
+		Flows:           flows,
+		ProxyEndpoints:  proxyutils.GetProxyEndpoints(apiProxy, proxiesFolder),
+		ProxySteps:      proxySteps,
+		TargetEndpoints: proxyutils.GetTargetEndpoints(apiProxy, targetsFolder),
+		TargetSteps:     targetSteps,
+	}
+	for _, policy := range generated {
+		conversion.Policies = append(conversion.Policies, policy.Name)
+	}
+	var flowNames []string
+	var proxyFlows []proxyutils.Flow
+	var routeRules []proxyutils.RouteRule
+	for _, flow := range flows {
+		flowNames = append(flowNames, flow.Name)
+		proxyFlows = append(proxyFlows, proxyutils.Flow{Name: flow.Name, Condition: flow.Condition})
+		if flow.NoRoute {
+			routeRules = append(routeRules, proxyutils.RouteRule{Name: flow.Name, Condition: flow.Condition})
+		}
+	}
+
+	//a previous run records what it added in the APIProxy Description. A policy
+	//with a generated name that is already in the bundle is treated the same
+	//way when it is identical to the generated one, so a proxy converted before
+	//the marker existed is not converted twice. A different policy with that
+	//name belongs to the proxy and is only replaced with -replace.
+	fingerprint := conversionFingerprint(proxySteps, targetSteps, generated, flows)
+	previousFingerprint, previousPolicies, previousFlows, previousFaultRules := proxyutils.GetConversionMarker(apiProxy)
+	if previousFingerprint == fingerprint {
+		Info.Println("Proxy ", proxyName, " is already converted")
+		conversion.Unchanged = true
+		return conversion, nil
+	}
+	for _, policyName := range proxyutils.GetPolicies(apiProxy) {
+		for _, policy := range generated {
+			if policy.Name != policyName || contains(previousPolicies, policyName) {
+				continue
+			}
+			existing, err := ioutil.ReadFile(policiesFolder + "/" + policyName + ".xml")
+			if err == nil && !proxyutils.EquivalentXML(existing, policy.Content) && !replace {
+				return Conversion{}, fmt.Errorf("the bundle already has a policy named %s, different from the generated one. Rename it or use -replace to replace it", policyName)
+			}
+			previousPolicies = append(previousPolicies, policyName)
+		}
+	}
+	if len(previousPolicies) > 0 {
+		Info.Println("Replacing policies added by a previous conversion: ", previousPolicies)
+		apiProxy = proxyutils.RemovePolicyAPIProxy(apiProxy, previousPolicies...)
+		apiProxy = proxyutils.RemoveResourceAPIProxy(apiProxy, scriptResources(previousPolicies)...)
+		for _, policyName := range previousPolicies {
+			if !contains(conversion.Policies, policyName) {
+				os.Remove(policiesFolder + "/" + policyName + ".xml")
+				os.Remove(scriptsFolder + "/" + policyName + ".js")
+			}
+		}
 	}
 
 	//create the policies folder
 	os.Mkdir(policiesFolder, 0777)
 
-	for _, plugin := range plugins {
-		if plugin == "oauth" {
-			if useJwt {
-				Info.Println("Adding VerifyJWT policy")
-				utils.CopyJWT(policiesFolder)
-				apiProxy = proxyutils.AddPolicyAPIProxy(apiProxy, extractVarName, kvmName, verifyJWTName, verifyApiKeyName)
-				proxyEndpoint = proxyutils.AddPolicyProxyEndpoint(proxyEndpoint, extractVarName, kvmName, verifyJWTName, verifyApiKeyName)
-				oauth = false
-			} else {
-				if mgconfig.APIKeyOnly(config) {
-					Info.Println("Adding VerifyAPIKey policy")
-					utils.CopyAPIKey(policiesFolder)
-					apiProxy = proxyutils.AddPolicyAPIProxy(apiProxy, verifyApiKeyName)
-					proxyEndpoint = proxyutils.AddPolicyProxyEndpoint(proxyEndpoint, verifyApiKeyName)
-					oauth = false
-				} else {
-					Info.Println("Adding OAuth v2.0 policy")
-					utils.CopyOAuth(policiesFolder)
-					apiProxy = proxyutils.AddPolicyAPIProxy(apiProxy, oauthPolicyName)
-					proxyEndpoint = proxyutils.AddPolicyProxyEndpoint(proxyEndpoint, oauthPolicyName)
-				}
+	var scripts []string
+	for _, policy := range generated {
+		Info.Println("Adding ", policy.Name, " policy")
+		err = utils.WritePolicy(policiesFolder, policy.Name, policy.Content)
+		if err != nil {
+			return Conversion{}, err
+		}
+		if policy.Script != nil {
+			os.MkdirAll(scriptsFolder, 0777)
+			err = utils.WriteScript(scriptsFolder, policy.Name, policy.Script)
+			if err != nil {
+				return Conversion{}, err
 			}
-		} else if plugin == "quota" {
-			Info.Println("Adding Quota policy")
-			utils.CopyQuota(policiesFolder, oauth)
-			apiProxy = proxyutils.AddPolicyAPIProxy(apiProxy, quotaPolicyName)
-			proxyEndpoint = proxyutils.AddPolicyProxyEndpoint(proxyEndpoint, quotaPolicyName)
-		} else if plugin == "spikearrest" {
-			Info.Println("Adding SpikeArrest policy")
-			Timeunit, Allow := mgconfig.GetSpikeArrestDetails(config)
-			utils.CopySpikeArrest(policiesFolder, Timeunit, Allow)
-			apiProxy = proxyutils.AddPolicyAPIProxy(apiProxy, spikeArrestName)
-			proxyEndpoint = proxyutils.AddPolicyProxyEndpoint(proxyEndpoint, spikeArrestName)
+			scripts = append(scripts, policy.Name)
 		}
 	}
 
-	err = proxyutils.WriteProxyEndpoint(proxyEndpoint, proxyEndpointXMLFile)
-	if err != nil {
-		Error.Fatalln("Error writing to ProxyEndpoint file:\n%#v\n", err)
-		return err
+	apiProxy = proxyutils.AddPolicyAPIProxy(apiProxy, conversion.Policies...)
+	if len(scripts) > 0 {
+		apiProxy = proxyutils.AddResourceAPIProxy(apiProxy, scriptResources(scripts)...)
 	}
+
+	//the DefaultFaultRules added by the conversion are removed with their
+	//last step, the ones that belong to the proxy are left as they are
+	var faultRuleEndpoints []string
+	for _, endpointName := range conversion.ProxyEndpoints {
+		Info.Println("Adding policies to ProxyEndpoint ", endpointName)
+		proxyEndpointXMLFile := proxiesFolder + "/" + endpointName + ".xml"
+		proxyEndpoint, err := proxyutils.ReadProxyEndpoint(proxyEndpointXMLFile)
+		if err != nil {
+			return Conversion{}, err
+		}
+		proxyEndpoint = proxyutils.RemovePolicyProxyEndpoint(proxyEndpoint, previousPolicies...)
+		proxyEndpoint = proxyutils.RemoveFlowsProxyEndpoint(proxyEndpoint, previousFlows...)
+		if contains(previousFaultRules, "proxies/"+endpointName) {
+			proxyutils.RemoveDefaultFaultRule(proxyEndpoint.Document)
+		}
+		hadFaultRule := proxyutils.HasDefaultFaultRule(proxyEndpoint.Document)
+		proxyEndpoint = proxyutils.AddFlowsProxyEndpoint(proxyEndpoint, proxyFlows...)
+		proxyEndpoint = proxyutils.AddRouteRulesProxyEndpoint(proxyEndpoint, routeRules...)
+		proxyEndpoint, err = proxyutils.AddStepsProxyEndpoint(proxyEndpoint, proxySteps...)
+		if err != nil {
+			return Conversion{}, fmt.Errorf("ProxyEndpoint %s: %v", endpointName, err)
+		}
+		if !hadFaultRule && proxyutils.HasDefaultFaultRule(proxyEndpoint.Document) {
+			faultRuleEndpoints = append(faultRuleEndpoints, "proxies/"+endpointName)
+		}
+		err = proxyutils.WriteProxyEndpoint(proxyEndpoint, proxyEndpointXMLFile)
+		if err != nil {
+			return Conversion{}, err
+		}
+	}
+
+	if len(targetSteps) > 0 || len(previousPolicies) > 0 {
+		for _, endpointName := range conversion.TargetEndpoints {
+			Info.Println("Adding policies to TargetEndpoint ", endpointName)
+			targetEndpointXMLFile := targetsFolder + "/" + endpointName + ".xml"
+			targetEndpoint, err := proxyutils.ReadTargetEndpoint(targetEndpointXMLFile)
+			if err != nil {
+				return Conversion{}, err
+			}
+			targetEndpoint = proxyutils.RemovePolicyTargetEndpoint(targetEndpoint, previousPolicies...)
+			if contains(previousFaultRules, "targets/"+endpointName) {
+				proxyutils.RemoveDefaultFaultRule(targetEndpoint.Document)
+			}
+			hadFaultRule := proxyutils.HasDefaultFaultRule(targetEndpoint.Document)
+			targetEndpoint, err = proxyutils.AddStepsTargetEndpoint(targetEndpoint, targetSteps...)
+			if err != nil {
+				return Conversion{}, fmt.Errorf("TargetEndpoint %s: %v", endpointName, err)
+			}
+			if !hadFaultRule && proxyutils.HasDefaultFaultRule(targetEndpoint.Document) {
+				faultRuleEndpoints = append(faultRuleEndpoints, "targets/"+endpointName)
+			}
+			err = proxyutils.WriteTargetEndpoint(targetEndpoint, targetEndpointXMLFile)
+			if err != nil {
+				return Conversion{}, err
+			}
+		}
+	}
+
+	apiProxy = proxyutils.SetConversionMarker(apiProxy, fingerprint, conversion.Policies, flowNames, faultRuleEndpoints)
 	err = proxyutils.WriteAPIProxy(apiProxy, apiProxyXMLFile)
 	if err != nil {
-		Error.Fatalln("Error writing to APIProxy file:\n%#v\n", err)
-		return err
+		return Conversion{}, err
 	}
 
-	return nil
+	return conversion, nil
+}
+
+// conversionFingerprint identifies the policies generated for a proxy and
+// where they are attached, so an unchanged configuration can be detected
+func conversionFingerprint(proxySteps []proxyutils.Step, targetSteps []proxyutils.Step, generated []converter.Policy, flows []converter.ConditionalFlow) string {
+	hash := sha256.New()
+	for _, policy := range generated {
+		fmt.Fprintf(hash, "%s\n%d\n", policy.Name, len(policy.Content))
+		hash.Write(policy.Content)
+		fmt.Fprintf(hash, "%d\n", len(policy.Script))
+		hash.Write(policy.Script)
+	}
+	for _, step := range proxySteps {
+		fmt.Fprintf(hash, "proxy %+v\n", step)
+	}
+	for _, step := range targetSteps {
+		fmt.Fprintf(hash, "target %+v\n", step)
+	}
+	for _, flow := range flows {
+		fmt.Fprintf(hash, "flow %+v\n", flow)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
 }
 
-func ExtractBundle(bundleName string) {
+// scriptResources returns the jsc resources of Javascript policies
+func scriptResources(policyNames []string) []string {
+	var resources []string
+	for _, policyName := range policyNames {
+		resources = append(resources, "jsc://"+policyName+".js")
+	}
+	return resources
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ConvertBundle converts a local proxy bundle, either a zip file or a folder
+// containing apiproxy/, and writes the result to fldr/<proxy name> along with
+// a zip file of the converted bundle.
+// With -plan the changes are printed and nothing is written.
+func ConvertBundle(source string, config mgconfig.Microgateway) ProxyResult {
+
+	result := ProxyResult{Proxy: source}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return result.fail("Error reading bundle", err)
+	}
+
+	//work on a copy so a failed conversion does not leave a half written bundle.
+	//The copy is made in the output folder so it can be moved there at the end.
+	if err = os.MkdirAll(fldr, os.ModePerm); err != nil {
+		return result.fail("Error creating output folder", err)
+	}
+	workFolder, err := ioutil.TempDir(fldr, "mgw2egw")
+	if err != nil {
+		return result.fail("Error creating output folder", err)
+	}
+	defer os.RemoveAll(workFolder)
+
+	//only the apiproxy folder goes into the converted bundle, whatever else
+	//the archive or the folder holds
+	apiproxyFolder := source
+	if !info.IsDir() {
+		extracted := filepath.Join(workFolder, "source")
+		if _, err = utils.Unzip(source, extracted); err != nil {
+			return result.fail("Error extracting bundle", err)
+		}
+		apiproxyFolder = filepath.Join(extracted, "apiproxy")
+	} else if filepath.Base(filepath.Clean(source)) != "apiproxy" {
+		apiproxyFolder = filepath.Join(source, "apiproxy")
+	}
+	if info, err := os.Stat(apiproxyFolder); err != nil || !info.IsDir() {
+		return result.fail("Error reading bundle", fmt.Errorf("%s has no apiproxy folder", source))
+	}
+
+	tmpFolder := filepath.Join(workFolder, "bundle")
+	if err = utils.CopyDir(apiproxyFolder, filepath.Join(tmpFolder, "apiproxy")); err != nil {
+		return result.fail("Error copying bundle", err)
+	}
+
+	proxyName, err := proxyutils.GetProxyName(tmpFolder)
+	if err != nil {
+		return result.fail("Error reading bundle", err)
+	}
+	Info.Println("Found proxy ", proxyName)
+	result.Proxy = proxyName
+
+	if !mgconfig.IsProxySet(proxyName, config) {
+		Warning.Println("Proxy ", proxyName, " is not listed in the Microgateway configuration file, converting anyway")
+	}
+
+	before, err := snapshotBundle(tmpFolder)
+	if err != nil {
+		return result.fail("Error reading bundle", err)
+	}
+
+	conversion, err := AddPolicies(proxyName, tmpFolder, config)
+	if err != nil {
+		return result.fail("Error adding policies", err)
+	}
+	result.Plugins = conversion.Plugins
+	result.Unconverted = conversion.Unconverted
+	result.Scaffolded = conversion.Scaffolded
+	result.Policies = conversion.Policies
+
+	if plan {
+		if err = printPlan(proxyName, source, conversion, nil, tmpFolder, before); err != nil {
+			return result.fail("Error printing plan", err)
+		}
+		result.Status = Planned
+		return result
+	}
+
+	bundleFolder := filepath.Join(fldr, proxyName)
+	bundleZip := bundleFolder + ".zip"
+	if err = utils.Zip(tmpFolder, bundleZip); err != nil {
+		return result.fail("Error writing bundle", err)
+	}
+
+	if err = os.MkdirAll(bundleFolder, os.ModePerm); err != nil {
+		return result.fail("Error writing bundle", err)
+	}
+	if err = os.RemoveAll(filepath.Join(bundleFolder, "apiproxy")); err != nil {
+		return result.fail("Error writing bundle", err)
+	}
+	err = os.Rename(filepath.Join(tmpFolder, "apiproxy"), filepath.Join(bundleFolder, "apiproxy"))
+	if err != nil {
+		return result.fail("Error writing bundle", err)
+	}
+
+	Info.Println("Converted bundle written to ", bundleZip)
+	result.Status = Converted
+	result.Reason = "bundle written to " + bundleZip
+	return result
+}
+
+func ExtractBundle(bundleName string) error {
 	bundlePart := strings.Split(bundleName, ".")[0]
-	utils.Unzip(bundleName, bundlePart)
+	_, err := utils.Unzip(bundleName, bundlePart)
+	return err
 }
 
 func GetLatestRevision(proxyName string, client *apigee.EdgeClient) (apigee.Revision, error) {
@@ -270,10 +777,12 @@ func GetLatestRevision(proxyName string, client *apigee.EdgeClient) (apigee.Revi
 	proxyRevs, resp, e := client.Proxies.Get(proxyName)
 
 	if e != nil {
-		Error.Fatalln("Error getting revision:\n%#v\n", e)
 		return revision, e
 	}
 	defer resp.Body.Close()
+	if len(proxyRevs.Revisions) == 0 {
+		return revision, fmt.Errorf("proxy %s has no revisions", proxyName)
+	}
 	return proxyRevs.Revisions[len(proxyRevs.Revisions)-1], nil
 }
 
@@ -281,8 +790,7 @@ func ImportProxy(proxyName string, bundleName string, client *apigee.EdgeClient)
 	bundlePart := strings.Split(bundleName, ".")[0]
 	proxyRev, resp, e := client.Proxies.Import(proxyName, bundlePart)
 	if e != nil {
-		Error.Fatalln("Error while importing proxy:\n%#v\n", e)
-		return proxyRev.Revision, e
+		return 0, e
 	}
 	defer resp.Body.Close()
 	return proxyRev.Revision, nil
@@ -292,15 +800,13 @@ func DeployProxy(proxyName string, env string, oldRevision apigee.Revision, newR
 
 	_, resp, e := client.Proxies.Undeploy(proxyName, env, oldRevision)
 	if e != nil {
-		Error.Fatalln("Error undeploying proxy:\n%#v\n", e)
-		return e
+		return fmt.Errorf("undeploying revision %d: %v", oldRevision, e)
 	}
 	resp.Body.Close()
 
 	_, resp, e = client.Proxies.Deploy(proxyName, env, newRevision)
 	if e != nil {
-		Error.Fatalln("Error deploying proxy:\n%#v\n", e)
-		return e
+		return fmt.Errorf("deploying revision %d: %v", newRevision, e)
 	}
 	resp.Body.Close()
 	return nil
@@ -310,7 +816,6 @@ func DownloadProxy(proxyName string, revision apigee.Revision, client *apigee.Ed
 
 	proxyRev, resp, e := client.Proxies.Export(proxyName, revision)
 	if e != nil {
-		Error.Fatalln("Error downloading proxy:\n%#v\n", e)
 		return proxyRev, e
 	}
 	defer resp.Body.Close()
@@ -342,6 +847,7 @@ func usage(message string) {
 	fmt.Println("mgw2egw version ", version)
 	fmt.Println("")
 	fmt.Println("Usage: mgw2egw -org=<orgname> -env=<envname> -user=<username> -pass=<password> -conf=<conf file>")
+	fmt.Println("       mgw2egw -bundle=<zip file or folder> -conf=<conf file>")
 	fmt.Println("")
 	fmt.Println("Options:")
 	fmt.Println("org  = Apigee Edge Organization name (mandatory)")
@@ -357,8 +863,19 @@ func usage(message string) {
 	fmt.Println("importonly = Import the proxies only, do not deploy")
 	fmt.Println("genonly = Generate the bundles only, do not import")
 	fmt.Println("usejwt = Use JWT policies to validate OAuth tokens")
+	fmt.Println("bundle = Convert a local proxy bundle (zip file or folder) offline, no credentials needed")
+	fmt.Println("report = Write a JSON report of the run to a file")
+	fmt.Println("keepgoing = Continue with the next proxy when a proxy fails (default: false)")
+	fmt.Println("replace = Replace the policies of a bundle that have the name of a generated policy (default: false)")
+	fmt.Println("plan = Show the changes for every proxy, do not import or deploy")
+	fmt.Println("templates = Folder with policy templates (<policy name>.xml) that replace the built-in policies")
+	fmt.Println("")
+	fmt.Println("Supported plugins: ", strings.Join(converter.Names(), ", "))
+	fmt.Println("")
+	fmt.Println("A summary of every proxy is printed at the end. The exit code is 2 when a proxy failed.")
 	fmt.Println("")
 	fmt.Println("")
 	fmt.Println("Example: mgw2egw -org=trial -env=test -user=trial@apigee.com -pass=Secret123 -config=trial-test-config.yaml")
+	fmt.Println("         mgw2egw -bundle=edgemicro_httpbin.zip -conf=trial-test-config.yaml -fldr=out")
 	os.Exit(1)
 }
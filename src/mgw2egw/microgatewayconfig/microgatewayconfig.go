@@ -15,22 +15,32 @@
 package microgatewayconfig
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
+	"path/filepath"
 )
 
 type Microgateway struct {
-	Edgeconfig  EdgeConfig  `yaml:"edge_config,omitempty"`
-	Edgemicro   EdgeMicro   `yaml:"edgemicro,omitempty"`
-	Header      Headers     `yaml:"headers,omitempty"`
-	Spikearrest SpikeArrest `yaml:"spikearrest,omitempty"`
-	Oauth       OAuth       `yaml:"oauth,omitempty"`
-	Ax          Analytics   `yaml:"analytics,omitempty"`
+	Edgeconfig    EdgeConfig    `yaml:"edge_config,omitempty"`
+	Edgemicro     EdgeMicro     `yaml:"edgemicro,omitempty"`
+	Header        Headers       `yaml:"headers,omitempty"`
+	Spikearrest   SpikeArrest   `yaml:"spikearrest,omitempty"`
+	Oauth         OAuth         `yaml:"oauth,omitempty"`
+	Ax            Analytics     `yaml:"analytics,omitempty"`
+	Cors          Cors          `yaml:"cors,omitempty"`
+	Accesscontrol AccessControl `yaml:"accesscontrol,omitempty"`
+	Healthcheck   Healthcheck   `yaml:"healthcheck,omitempty"`
+	Extauth       ExtAuth       `yaml:"extauth,omitempty"`
+	Oauthv2       OAuthV2       `yaml:"oauthv2,omitempty"`
+	Apikeys       APIKeys       `yaml:"apikeys,omitempty"`
+	Quotas        Quotas        `yaml:"quotas,omitempty"`
 }
 
 type EdgeConfig struct {
 	Bootstrap        string `yaml:"bootstrap,omitempty"`
 	JwtPublicKey     string `yaml:"jwt_public_key,omitempty"`
+	JwkPublicKeys    string `yaml:"jwk_public_keys,omitempty"`
 	Management       string `yaml:"managementUri,omitempty"`
 	Vaultname        string `yaml:"vaultName,omitempty"`
 	Auth             string `yaml:"authUri,omitempty"`
@@ -57,6 +67,7 @@ type Logging struct {
 }
 
 type Plugins struct {
+	Dir      string   `yaml:"dir,omitempty"`
 	Sequence []string `yaml:"sequence,omitempty"`
 }
 
@@ -80,19 +91,175 @@ type OAuth struct {
 	CacheKey                  string `yaml:"cacheKey,omitempty"`
 	VerifyApiKeyUrl           string `yaml:"verify_api_key_url,omitempty"`
 	AllowAPIKeyOnly           bool   `yaml:"allowAPIKeyOnly,omitempty"`
+	ApiKeyHeader              string `yaml:"api-key-header,omitempty"`
+	TokenCache                bool   `yaml:"tokenCache,omitempty"`
+	TokenCacheSize            int    `yaml:"tokenCacheSize,omitempty"`
+	KeepAuthorizationHeader   bool   `yaml:"keep-authorization-header,omitempty"`
+}
+
+type APIKeys struct {
+	AllowNoAuthorization      bool   `yaml:"allowNoAuthorization,omitempty"`
+	AllowInvalidAuthorization bool   `yaml:"allowInvalidAuthorization,omitempty"`
+	ApiKeyHeader              string `yaml:"api-key-header,omitempty"`
+}
+
+type OAuthV2 struct {
+	AllowNoAuthorization      bool   `yaml:"allowNoAuthorization,omitempty"`
+	AllowInvalidAuthorization bool   `yaml:"allowInvalidAuthorization,omitempty"`
+	JwkPublicKeys             string `yaml:"jwk_public_keys,omitempty"`
+	GracePeriod               string `yaml:"gracePeriod,omitempty"`
+	TokenCache                bool   `yaml:"tokenCache,omitempty"`
+	TokenCacheSize            int    `yaml:"tokenCacheSize,omitempty"`
+	KeepAuthorizationHeader   bool   `yaml:"keep-authorization-header,omitempty"`
 }
 
 type SpikeArrest struct {
 	TimeUnit   string `yaml:"timeUnit,omitempty"`
 	Allow      int    `yaml:"allow,omitempty"`
-	Buffersize int    `yaml:"buffersize,omitempty"`
+	Buffersize int    `yaml:"bufferSize,omitempty"`
+	//Identifier keeps a separate rate per value of an Edge flow variable, client_id for the calling app
+	Identifier string `yaml:"identifier,omitempty"`
+}
+
+type Cors struct {
+	Origin           string `yaml:"origin,omitempty"`
+	Methods          string `yaml:"methods,omitempty"`
+	AllowHeaders     string `yaml:"allowHeaders,omitempty"`
+	MaxAge           int    `yaml:"maxAge,omitempty"`
+	AllowCredentials bool   `yaml:"allowCredentials,omitempty"`
+}
+
+type AccessControl struct {
+	Allow             []string `yaml:"allow,omitempty"`
+	Deny              []string `yaml:"deny,omitempty"`
+	NoRuleMatchAction string   `yaml:"noRuleMatchAction,omitempty"`
+	//DenyFirst is set when deny comes before allow in the configuration file
+	DenyFirst bool `yaml:"-"`
+}
+
+// UnmarshalYAML records the order of the allow and deny lists, microgateway
+// checks them in the order they are configured
+func (accessControl *AccessControl) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain AccessControl
+	if err := unmarshal((*plain)(accessControl)); err != nil {
+		return err
+	}
+	var section yaml.MapSlice
+	if err := unmarshal(&section); err != nil {
+		return err
+	}
+	for _, item := range section {
+		if item.Key == "allow" {
+			break
+		}
+		if item.Key == "deny" {
+			accessControl.DenyFirst = true
+			break
+		}
+	}
+	return nil
+}
+
+type Healthcheck struct {
+	Url string `yaml:"healthcheck_url,omitempty"`
+}
+
+type ExtAuth struct {
+	PublickeyUrl string `yaml:"publickey_url,omitempty"`
+	//ClientId is the claim that holds the client id of the caller
+	ClientId       string `yaml:"client_id,omitempty"`
+	Iss            string `yaml:"iss,omitempty"`
+	Exp            *bool  `yaml:"exp,omitempty"`
+	SendErr        *bool  `yaml:"sendErr,omitempty"`
+	KeepAuthHeader bool   `yaml:"keepAuthHeader,omitempty"`
+}
+
+type Quota struct {
+	BufferSize int    `yaml:"bufferSize,omitempty"`
+	TimeUnit   string `yaml:"timeUnit,omitempty"`
+	Interval   int    `yaml:"interval,omitempty"`
+	Allow      int    `yaml:"allow,omitempty"`
+	UseRedis   bool   `yaml:"useRedis,omitempty"`
+	QuotaType  string `yaml:"quotaType,omitempty"`
+}
+
+// ProductQuota is the quota configuration of an API product
+type ProductQuota struct {
+	Product string
+	Quota
+}
+
+// QuotaSettings are the keys of the quotas section that apply to every product
+type QuotaSettings struct {
+	//BufferSize is keyed by time unit, default for the other units
+	BufferSize                        map[string]int `yaml:"bufferSize,omitempty"`
+	UseRedis                          bool           `yaml:"useRedis,omitempty"`
+	FailOpen                          bool           `yaml:"failOpen,omitempty"`
+	UseDebugMpId                      bool           `yaml:"useDebugMpId,omitempty"`
+	IsHTTPStatusTooManyRequestEnabled bool           `yaml:"isHTTPStatusTooManyRequestEnabled,omitempty"`
+}
+
+// Quotas is the quotas section: the settings shared by every product and
+// the per product entries, in the order of the configuration file
+type Quotas struct {
+	QuotaSettings
+	Products []ProductQuota
+}
+
+// UnmarshalYAML reads the settings of the quotas section, every other key is an API product
+func (quotas *Quotas) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var section yaml.MapSlice
+	if err := unmarshal(&section); err != nil {
+		return err
+	}
+	var settings yaml.MapSlice
+	for _, item := range section {
+		key, ok := item.Key.(string)
+		if !ok {
+			continue
+		}
+		switch key {
+		case "bufferSize", "useRedis", "failOpen", "useDebugMpId", "isHTTPStatusTooManyRequestEnabled":
+			settings = append(settings, item)
+			continue
+		}
+		content, err := yaml.Marshal(item.Value)
+		if err != nil {
+			return err
+		}
+		quota := ProductQuota{Product: key}
+		if err = yaml.Unmarshal(content, &quota.Quota); err != nil {
+			return fmt.Errorf("quotas.%s: %v", key, err)
+		}
+		quotas.Products = append(quotas.Products, quota)
+	}
+	content, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(content, &quotas.QuotaSettings); err != nil {
+		return fmt.Errorf("quotas: %v", err)
+	}
+	return nil
+}
+
+// ProductBufferSize returns the bufferSize of a product, or the one set for
+// its time unit in the quotas section
+func (quotas Quotas) ProductBufferSize(productQuota ProductQuota) int {
+	if productQuota.BufferSize > 0 {
+		return productQuota.BufferSize
+	}
+	if bufferSize, ok := quotas.BufferSize[productQuota.TimeUnit]; ok {
+		return bufferSize
+	}
+	return quotas.BufferSize["default"]
 }
 
 var proxyMap = map[string]string{}
 
-func ReadConfig(filepath string) (microgateway Microgateway, err error) {
+func ReadConfig(fileName string) (microgateway Microgateway, err error) {
 	var config Microgateway
-	source, err := ioutil.ReadFile(filepath)
+	source, err := ioutil.ReadFile(fileName)
 
 	if err != nil {
 		return Microgateway{}, err
@@ -102,6 +269,10 @@ func ReadConfig(filepath string) (microgateway Microgateway, err error) {
 		return Microgateway{}, err
 	}
 
+	if dir := config.Edgemicro.Plugin.Dir; dir != "" && !filepath.IsAbs(dir) {
+		config.Edgemicro.Plugin.Dir = filepath.Join(filepath.Dir(fileName), dir)
+	}
+
 	if len(config.Edgemicro.Proxies) > 0 {
 		for _, proxy := range config.Edgemicro.Proxies {
 			proxyMap[proxy] = proxy
@@ -115,6 +286,12 @@ func GetPlugins(microgateway Microgateway) []string {
 	return microgateway.Edgemicro.Plugin.Sequence
 }
 
+// GetPluginsDir returns the folder of the custom plugins, relative paths
+// are resolved against the folder of the configuration file
+func GetPluginsDir(microgateway Microgateway) string {
+	return microgateway.Edgemicro.Plugin.Dir
+}
+
 func GetProxies(microgateway Microgateway) []string {
 	return microgateway.Edgemicro.Proxies
 }
@@ -132,8 +309,59 @@ func APIKeyOnly(microgateway Microgateway) bool {
 	return microgateway.Oauth.AllowAPIKeyOnly
 }
 
-func GetSpikeArrestDetails(microgateway Microgateway) (string, int) {
-	return microgateway.Spikearrest.TimeUnit, microgateway.Spikearrest.Allow
+func GetOAuthDetails(microgateway Microgateway) OAuth {
+	return microgateway.Oauth
+}
+
+func GetOAuthV2Details(microgateway Microgateway) OAuthV2 {
+	return microgateway.Oauthv2
+}
+
+func GetAPIKeysDetails(microgateway Microgateway) APIKeys {
+	return microgateway.Apikeys
+}
+
+func GetQuotas(microgateway Microgateway) Quotas {
+	return microgateway.Quotas
+}
+
+func GetSpikeArrestDetails(microgateway Microgateway) SpikeArrest {
+	return microgateway.Spikearrest
+}
+
+func GetHeaders(microgateway Microgateway) Headers {
+	return microgateway.Header
+}
+
+// AddsHeaders returns true when the headers section enables at least one header
+func AddsHeaders(microgateway Microgateway) bool {
+	return microgateway.Header != Headers{}
+}
+
+func GetCorsDetails(microgateway Microgateway) Cors {
+	return microgateway.Cors
+}
+
+func GetAccessControlDetails(microgateway Microgateway) AccessControl {
+	return microgateway.Accesscontrol
+}
+
+func GetHealthcheckUrl(microgateway Microgateway) string {
+	return microgateway.Healthcheck.Url
+}
+
+func GetExtAuthDetails(microgateway Microgateway) ExtAuth {
+	return microgateway.Extauth
+}
+
+// GetJwkPublicKeys returns the JWKS that signs the tokens checked by the
+// oauthv2 plugin, either its url or, in a downloaded configuration, the JWKS
+// document itself, or "" when the public key from the vault is used
+func GetJwkPublicKeys(microgateway Microgateway) string {
+	if microgateway.Oauthv2.JwkPublicKeys != "" {
+		return microgateway.Oauthv2.JwkPublicKeys
+	}
+	return microgateway.Edgeconfig.JwkPublicKeys
 }
 
 /*func main() {
@@ -0,0 +1,139 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mgw2egw/proxyutils"
+	"mgw2egw/utils"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// snapshotBundle reads the APIProxy descriptor, the endpoint files and the
+// JavaScript resources of a bundle, keyed by their path in the bundle, so they can be compared after
+// the conversion
+func snapshotBundle(bundleFolder string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, pattern := range []string{"*.xml", "proxies/*.xml", "targets/*.xml", "resources/jsc/*.js"} {
+		matches, err := filepath.Glob(filepath.Join(bundleFolder, "apiproxy", pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			content, err := ioutil.ReadFile(match)
+			if err != nil {
+				return nil, err
+			}
+			relPath, err := filepath.Rel(bundleFolder, match)
+			if err != nil {
+				return nil, err
+			}
+			files[filepath.ToSlash(relPath)] = content
+		}
+	}
+	return files, nil
+}
+
+// printPlan prints what a conversion changed in a bundle: the source of the
+// bundle, the policies and steps added, the calls that would be made to
+// Apigee Edge and a diff of every file that changed
+func printPlan(proxyName string, source string, conversion Conversion, calls []string, bundleFolder string, before map[string][]byte) error {
+
+	fmt.Println("Plan for proxy " + proxyName + ":")
+	fmt.Println("  Source: " + source)
+	if conversion.Unchanged {
+		fmt.Println("  Already converted with the same policies, nothing to do")
+		fmt.Println("")
+		return nil
+	}
+	fmt.Println("  Add policies: " + strings.Join(conversion.Policies, ", "))
+	for _, endpoint := range conversion.ProxyEndpoints {
+		for _, flow := range conversion.Flows {
+			line := "  Add flow " + flow.Name + " to ProxyEndpoint " + endpoint + " when " + flow.Condition
+			if flow.NoRoute {
+				line += ", answered without calling the target"
+			}
+			fmt.Println(line)
+		}
+		printSteps("ProxyEndpoint "+endpoint, conversion.ProxySteps)
+	}
+	for _, endpoint := range conversion.TargetEndpoints {
+		printSteps("TargetEndpoint "+endpoint, conversion.TargetSteps)
+	}
+	for _, call := range calls {
+		fmt.Println("  " + call)
+	}
+
+	after, err := snapshotBundle(bundleFolder)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for file := range after {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		diff := utils.UnifiedDiff("a/"+file, "b/"+file, before[file], after[file])
+		if diff != "" {
+			fmt.Println("")
+			fmt.Print(diff)
+		}
+	}
+	fmt.Println("")
+	return nil
+}
+
+// printSteps prints the steps added to an endpoint, grouped by flow in the
+// order they run, steps marked First ahead of the others
+func printSteps(endpoint string, steps []proxyutils.Step) {
+	var flows []string
+	policyNames := map[string][]string{}
+	var ordered []proxyutils.Step
+	for _, step := range steps {
+		if step.First {
+			ordered = append(ordered, step)
+		}
+	}
+	for _, step := range steps {
+		if !step.First {
+			ordered = append(ordered, step)
+		}
+	}
+	for _, step := range ordered {
+		flow := step.Flow
+		if flow == "" {
+			flow = "PreFlow"
+		}
+		switch {
+		case step.Flow == proxyutils.DefaultFaultRule:
+		case step.Response:
+			flow += " response"
+		default:
+			flow += " request"
+		}
+		if _, ok := policyNames[flow]; !ok {
+			flows = append(flows, flow)
+		}
+		policyNames[flow] = append(policyNames[flow], step.Policy)
+	}
+	for _, flow := range flows {
+		fmt.Println("  Insert " + flow + " steps in " + endpoint + ": " + strings.Join(policyNames[flow], ", "))
+	}
+}
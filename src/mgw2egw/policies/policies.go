@@ -0,0 +1,645 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policies
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+const header string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// Common holds the attributes shared by every policy
+type Common struct {
+	Async           bool   `xml:"async,attr"`
+	ContinueOnError bool   `xml:"continueOnError,attr"`
+	Enabled         bool   `xml:"enabled,attr"`
+	Name            string `xml:"name,attr"`
+}
+
+type Properties struct {
+	Property []Property `xml:"Property,omitempty"`
+}
+
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Ref is an element whose value can be read from a flow variable
+type Ref struct {
+	Ref   string `xml:"ref,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type OAuthV2 struct {
+	XMLName xml.Name `xml:"OAuthV2"`
+	Common
+	DisplayName           string           `xml:"DisplayName"`
+	Properties            Properties       `xml:"Properties"`
+	Attributes            struct{}         `xml:"Attributes"`
+	ExternalAuthorization bool             `xml:"ExternalAuthorization"`
+	Operation             string           `xml:"Operation"`
+	SupportedGrantTypes   struct{}         `xml:"SupportedGrantTypes"`
+	GenerateResponse      GenerateResponse `xml:"GenerateResponse"`
+	Tokens                struct{}         `xml:"Tokens"`
+}
+
+type GenerateResponse struct {
+	Enabled bool `xml:"enabled,attr"`
+}
+
+type Quota struct {
+	XMLName xml.Name `xml:"Quota"`
+	Common
+	Type                      string                     `xml:"type,attr,omitempty"`
+	DisplayName               string                     `xml:"DisplayName"`
+	Properties                Properties                 `xml:"Properties"`
+	Allow                     QuotaAllow                 `xml:"Allow"`
+	Interval                  Ref                        `xml:"Interval"`
+	Identifier                *Ref                       `xml:"Identifier,omitempty"`
+	Distributed               bool                       `xml:"Distributed"`
+	Synchronous               bool                       `xml:"Synchronous"`
+	AsynchronousConfiguration *AsynchronousConfiguration `xml:"AsynchronousConfiguration,omitempty"`
+	PreciseAtSecondsLevel     bool                       `xml:"PreciseAtSecondsLevel"`
+	TimeUnit                  Ref                        `xml:"TimeUnit"`
+}
+
+// AsynchronousConfiguration syncs a distributed quota counter every SyncMessageCount requests
+type AsynchronousConfiguration struct {
+	SyncMessageCount int `xml:"SyncMessageCount"`
+}
+
+type QuotaAllow struct {
+	Count    int    `xml:"count,attr,omitempty"`
+	CountRef string `xml:"countRef,attr,omitempty"`
+}
+
+type SpikeArrest struct {
+	XMLName xml.Name `xml:"SpikeArrest"`
+	Common
+	DisplayName       string     `xml:"DisplayName"`
+	Properties        Properties `xml:"Properties"`
+	Identifier        *Ref       `xml:"Identifier,omitempty"`
+	Rate              string     `xml:"Rate"`
+	UseEffectiveCount bool       `xml:"UseEffectiveCount,omitempty"`
+}
+
+type VerifyAPIKey struct {
+	XMLName xml.Name `xml:"VerifyAPIKey"`
+	Common
+	DisplayName string     `xml:"DisplayName"`
+	Properties  Properties `xml:"Properties"`
+	APIKey      Ref        `xml:"APIKey"`
+}
+
+type VerifyJWT struct {
+	XMLName xml.Name `xml:"VerifyJWT"`
+	Common
+	DisplayName   string        `xml:"DisplayName"`
+	Algorithm     string        `xml:"Algorithm"`
+	Source        string        `xml:"Source,omitempty"`
+	PublicKey     PublicKey     `xml:"PublicKey"`
+	Issuer        string        `xml:"Issuer,omitempty"`
+	CustomClaims  *CustomClaims `xml:"CustomClaims,omitempty"`
+	TimeAllowance string        `xml:"TimeAllowance,omitempty"`
+}
+
+type PublicKey struct {
+	Value *Ref  `xml:"Value,omitempty"`
+	JWKS  *JWKS `xml:"JWKS,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set fetched from Uri, or given inline in Value
+type JWKS struct {
+	Uri   string `xml:"uri,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type CustomClaims struct {
+	Claim []Claim `xml:"Claim"`
+}
+
+type Claim struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type KeyValueMapOperations struct {
+	XMLName xml.Name `xml:"KeyValueMapOperations"`
+	Common
+	MapIdentifier    string     `xml:"mapIdentifier,attr"`
+	DisplayName      string     `xml:"DisplayName"`
+	Properties       Properties `xml:"Properties"`
+	ExclusiveCache   bool       `xml:"ExclusiveCache"`
+	ExpiryTimeInSecs int        `xml:"ExpiryTimeInSecs"`
+	Get              []KVMGet   `xml:"Get"`
+	Scope            string     `xml:"Scope"`
+}
+
+type KVMGet struct {
+	AssignTo string `xml:"assignTo,attr"`
+	Index    int    `xml:"index,attr"`
+	Key      KVMKey `xml:"Key"`
+}
+
+type KVMKey struct {
+	Parameter []string `xml:"Parameter"`
+}
+
+type ExtractVariables struct {
+	XMLName xml.Name `xml:"ExtractVariables"`
+	Common
+	DisplayName               string          `xml:"DisplayName"`
+	Properties                Properties      `xml:"Properties"`
+	Header                    []ExtractHeader `xml:"Header"`
+	IgnoreUnresolvedVariables bool            `xml:"IgnoreUnresolvedVariables"`
+	Source                    ExtractSource   `xml:"Source"`
+}
+
+type ExtractHeader struct {
+	Name    string    `xml:"name,attr"`
+	Pattern []Pattern `xml:"Pattern"`
+}
+
+type Pattern struct {
+	IgnoreCase bool   `xml:"ignoreCase,attr"`
+	Value      string `xml:",chardata"`
+}
+
+type ExtractSource struct {
+	ClearPayload bool   `xml:"clearPayload,attr"`
+	Value        string `xml:",chardata"`
+}
+
+type AssignMessage struct {
+	XMLName xml.Name `xml:"AssignMessage"`
+	Common
+	DisplayName               string           `xml:"DisplayName"`
+	Properties                Properties       `xml:"Properties"`
+	Remove                    *AssignRemove    `xml:"Remove,omitempty"`
+	Set                       *AssignSet       `xml:"Set,omitempty"`
+	AssignVariable            []AssignVariable `xml:"AssignVariable,omitempty"`
+	IgnoreUnresolvedVariables bool             `xml:"IgnoreUnresolvedVariables"`
+	AssignTo                  AssignTo         `xml:"AssignTo"`
+}
+
+type AssignVariable struct {
+	Name     string `xml:"Name"`
+	Ref      string `xml:"Ref,omitempty"`
+	Template string `xml:"Template,omitempty"`
+}
+
+type AssignRemove struct {
+	Headers *Headers `xml:"Headers,omitempty"`
+}
+
+type AssignSet struct {
+	Headers      *Headers `xml:"Headers,omitempty"`
+	Payload      *Payload `xml:"Payload,omitempty"`
+	StatusCode   int      `xml:"StatusCode,omitempty"`
+	ReasonPhrase string   `xml:"ReasonPhrase,omitempty"`
+}
+
+// Payload is a message body, flow variables are referenced between
+// VariablePrefix and VariableSuffix, { and } by default
+type Payload struct {
+	ContentType    string `xml:"contentType,attr,omitempty"`
+	VariablePrefix string `xml:"variablePrefix,attr,omitempty"`
+	VariableSuffix string `xml:"variableSuffix,attr,omitempty"`
+	Value          string `xml:",chardata"`
+}
+
+type Headers struct {
+	Header []Header `xml:"Header"`
+}
+
+type Header struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type AssignTo struct {
+	CreateNew bool   `xml:"createNew,attr"`
+	Transport string `xml:"transport,attr"`
+	Type      string `xml:"type,attr"`
+}
+
+type JSONToXML struct {
+	XMLName xml.Name `xml:"JSONToXML"`
+	Common
+	DisplayName    string     `xml:"DisplayName"`
+	Properties     Properties `xml:"Properties"`
+	Options        Options    `xml:"Options"`
+	OutputVariable string     `xml:"OutputVariable"`
+	Source         string     `xml:"Source"`
+}
+
+type XMLToJSON struct {
+	XMLName xml.Name `xml:"XMLToJSON"`
+	Common
+	DisplayName    string     `xml:"DisplayName"`
+	Properties     Properties `xml:"Properties"`
+	Options        Options    `xml:"Options"`
+	OutputVariable string     `xml:"OutputVariable"`
+	Source         string     `xml:"Source"`
+}
+
+// Options keeps the default options of the JSONToXML and XMLToJSON policies
+type Options struct {
+}
+
+type Javascript struct {
+	XMLName xml.Name `xml:"Javascript"`
+	Common
+	TimeLimit   int        `xml:"timeLimit,attr"`
+	DisplayName string     `xml:"DisplayName"`
+	Properties  Properties `xml:"Properties"`
+	ResourceURL string     `xml:"ResourceURL,omitempty"`
+	Source      string     `xml:"Source,omitempty"`
+}
+
+type AccessControl struct {
+	XMLName xml.Name `xml:"AccessControl"`
+	Common
+	DisplayName string     `xml:"DisplayName"`
+	Properties  Properties `xml:"Properties"`
+	IPRules     IPRules    `xml:"IPRules"`
+}
+
+type IPRules struct {
+	NoRuleMatchAction string      `xml:"noRuleMatchAction,attr"`
+	MatchRule         []MatchRule `xml:"MatchRule"`
+}
+
+type MatchRule struct {
+	Action        string          `xml:"action,attr"`
+	SourceAddress []SourceAddress `xml:"SourceAddress"`
+}
+
+type SourceAddress struct {
+	Mask  int    `xml:"mask,attr"`
+	Value string `xml:",chardata"`
+}
+
+type LookupCache struct {
+	XMLName xml.Name `xml:"LookupCache"`
+	Common
+	DisplayName string     `xml:"DisplayName"`
+	Properties  Properties `xml:"Properties"`
+	CacheKey    CacheKey   `xml:"CacheKey"`
+	Scope       string     `xml:"Scope"`
+	AssignTo    string     `xml:"AssignTo"`
+}
+
+type PopulateCache struct {
+	XMLName xml.Name `xml:"PopulateCache"`
+	Common
+	DisplayName    string         `xml:"DisplayName"`
+	Properties     Properties     `xml:"Properties"`
+	CacheKey       CacheKey       `xml:"CacheKey"`
+	Scope          string         `xml:"Scope"`
+	ExpirySettings ExpirySettings `xml:"ExpirySettings"`
+	Source         string         `xml:"Source"`
+}
+
+type CacheKey struct {
+	KeyFragment []Ref `xml:"KeyFragment"`
+}
+
+type ExpirySettings struct {
+	TimeoutInSec Ref `xml:"TimeoutInSec"`
+}
+
+func common(name string) Common {
+	return Common{Async: false, ContinueOnError: false, Enabled: true, Name: name}
+}
+
+// NewOAuthV2 returns an OAuthV2 policy that verifies the access token
+func NewOAuthV2(name string) *OAuthV2 {
+	return &OAuthV2{
+		Common:           common(name),
+		DisplayName:      name,
+		Operation:        "VerifyAccessToken",
+		GenerateResponse: GenerateResponse{Enabled: true},
+	}
+}
+
+// NewQuota returns a calendar Quota policy that reads its limits from the
+// API product. apiKeyPolicy is the name of the VerifyAPIKey policy that
+// resolves the product, or "" when an OAuthV2 policy does.
+func NewQuota(name string, apiKeyPolicy string) *Quota {
+	prefix := "apiproduct.developer.quota."
+	if apiKeyPolicy != "" {
+		prefix = "verifyapikey." + apiKeyPolicy + "." + prefix
+	}
+	return &Quota{
+		Common:      common(name),
+		Type:        "calendar",
+		DisplayName: name,
+		Allow:       QuotaAllow{Count: 200, CountRef: prefix + "limit"},
+		Interval:    Ref{Ref: prefix + "interval", Value: "1"},
+		Distributed: true,
+		Synchronous: true,
+		TimeUnit:    Ref{Ref: prefix + "timeunit", Value: "hour"},
+	}
+}
+
+// SetIdentifier keeps a separate counter for each value of the variable ref
+func (quota *Quota) SetIdentifier(ref string) *Quota {
+	quota.Identifier = &Ref{Ref: ref}
+	return quota
+}
+
+// SetLocal keeps a separate counter on each message processor instead of a distributed one
+func (quota *Quota) SetLocal() *Quota {
+	quota.Distributed = false
+	quota.Synchronous = false
+	quota.AsynchronousConfiguration = nil
+	return quota
+}
+
+// SetAsynchronous syncs the distributed counter every syncMessageCount requests instead of on every request
+func (quota *Quota) SetAsynchronous(syncMessageCount int) *Quota {
+	quota.Synchronous = false
+	quota.AsynchronousConfiguration = &AsynchronousConfiguration{SyncMessageCount: syncMessageCount}
+	return quota
+}
+
+// NewSpikeArrest returns a SpikeArrest policy with a rate such as 30ps or 10pm
+func NewSpikeArrest(name string, rate string) *SpikeArrest {
+	return &SpikeArrest{
+		Common:      common(name),
+		DisplayName: name,
+		Rate:        rate,
+	}
+}
+
+// SetIdentifier keeps a separate rate for each value of the variable ref
+func (spikeArrest *SpikeArrest) SetIdentifier(ref string) *SpikeArrest {
+	spikeArrest.Identifier = &Ref{Ref: ref}
+	return spikeArrest
+}
+
+// NewVerifyAPIKey returns a VerifyAPIKey policy that reads the key from the variable ref
+func NewVerifyAPIKey(name string, ref string) *VerifyAPIKey {
+	return &VerifyAPIKey{
+		Common:      common(name),
+		DisplayName: name,
+		APIKey:      Ref{Ref: ref},
+	}
+}
+
+// NewVerifyJWT returns an RS256 VerifyJWT policy that verifies the token in
+// the variable source with the public key in the variable publicKeyRef
+func NewVerifyJWT(name string, source string, publicKeyRef string) *VerifyJWT {
+	return &VerifyJWT{
+		Common:      common(name),
+		DisplayName: name,
+		Algorithm:   "RS256",
+		Source:      source,
+		PublicKey:   PublicKey{Value: &Ref{Ref: publicKeyRef}},
+	}
+}
+
+// NewVerifyJWTWithJWKS returns an RS256 VerifyJWT policy that verifies the
+// bearer token in the Authorization header with the keys published at jwksUri
+func NewVerifyJWTWithJWKS(name string, jwksUri string) *VerifyJWT {
+	return &VerifyJWT{
+		Common:      common(name),
+		DisplayName: name,
+		Algorithm:   "RS256",
+		PublicKey:   PublicKey{JWKS: &JWKS{Uri: jwksUri}},
+	}
+}
+
+// NewVerifyJWTWithKeySet returns an RS256 VerifyJWT policy that verifies the
+// bearer token in the Authorization header with the keys of the JWKS document jwks
+func NewVerifyJWTWithKeySet(name string, jwks string) *VerifyJWT {
+	return &VerifyJWT{
+		Common:      common(name),
+		DisplayName: name,
+		Algorithm:   "RS256",
+		PublicKey:   PublicKey{JWKS: &JWKS{Value: jwks}},
+	}
+}
+
+// SetTimeAllowance accepts tokens that expired, or are not valid yet, by up to timeAllowance, such as 10s
+func (verifyJWT *VerifyJWT) SetTimeAllowance(timeAllowance string) *VerifyJWT {
+	verifyJWT.TimeAllowance = timeAllowance
+	return verifyJWT
+}
+
+// SetIssuer requires the iss claim to be issuer
+func (verifyJWT *VerifyJWT) SetIssuer(issuer string) *VerifyJWT {
+	verifyJWT.Issuer = issuer
+	return verifyJWT
+}
+
+// AddClaim requires the custom claim name to have value
+func (verifyJWT *VerifyJWT) AddClaim(name string, value string) *VerifyJWT {
+	if verifyJWT.CustomClaims == nil {
+		verifyJWT.CustomClaims = &CustomClaims{}
+	}
+	verifyJWT.CustomClaims.Claim = append(verifyJWT.CustomClaims.Claim, Claim{Name: name, Value: value})
+	return verifyJWT
+}
+
+// NewKeyValueMapOperations returns a KeyValueMapOperations policy on an environment scoped map
+func NewKeyValueMapOperations(name string, mapIdentifier string) *KeyValueMapOperations {
+	return &KeyValueMapOperations{
+		Common:           common(name),
+		MapIdentifier:    mapIdentifier,
+		DisplayName:      name,
+		ExpiryTimeInSecs: 300,
+		Scope:            "environment",
+	}
+}
+
+// AddGet reads key from the map into the variable assignTo
+func (kvm *KeyValueMapOperations) AddGet(assignTo string, key string) *KeyValueMapOperations {
+	kvm.Get = append(kvm.Get, KVMGet{AssignTo: assignTo, Index: 1, Key: KVMKey{Parameter: []string{key}}})
+	return kvm
+}
+
+// NewExtractVariables returns an ExtractVariables policy on the request
+func NewExtractVariables(name string) *ExtractVariables {
+	return &ExtractVariables{
+		Common:                    common(name),
+		DisplayName:               name,
+		IgnoreUnresolvedVariables: true,
+		Source:                    ExtractSource{Value: "request"},
+	}
+}
+
+// AddHeader extracts variables from a request header using pattern
+func (extractVariables *ExtractVariables) AddHeader(name string, pattern string) *ExtractVariables {
+	extractVariables.Header = append(extractVariables.Header, ExtractHeader{Name: name, Pattern: []Pattern{{Value: pattern}}})
+	return extractVariables
+}
+
+// NewAssignMessage returns an AssignMessage policy that changes the current
+// message of messageType, request or response
+func NewAssignMessage(name string, messageType string) *AssignMessage {
+	return &AssignMessage{
+		Common:                    common(name),
+		DisplayName:               name,
+		IgnoreUnresolvedVariables: true,
+		AssignTo:                  AssignTo{CreateNew: false, Transport: "http", Type: messageType},
+	}
+}
+
+// RemoveHeader removes a header from the message
+func (assignMessage *AssignMessage) RemoveHeader(name string) *AssignMessage {
+	if assignMessage.Remove == nil {
+		assignMessage.Remove = &AssignRemove{Headers: &Headers{}}
+	}
+	assignMessage.Remove.Headers.Header = append(assignMessage.Remove.Headers.Header, Header{Name: name})
+	return assignMessage
+}
+
+// SetVariable sets the flow variable name to the value of the variable ref
+func (assignMessage *AssignMessage) SetVariable(name string, ref string) *AssignMessage {
+	assignMessage.AssignVariable = append(assignMessage.AssignVariable, AssignVariable{Name: name, Ref: ref})
+	return assignMessage
+}
+
+// SetVariableTemplate sets the flow variable name to a message template such as {client_id}-{apiproduct.name}
+func (assignMessage *AssignMessage) SetVariableTemplate(name string, template string) *AssignMessage {
+	assignMessage.AssignVariable = append(assignMessage.AssignVariable, AssignVariable{Name: name, Template: template})
+	return assignMessage
+}
+
+// SetHeader sets a header of the message, value can reference flow variables as {variable}
+func (assignMessage *AssignMessage) SetHeader(name string, value string) *AssignMessage {
+	set := assignMessage.set()
+	if set.Headers == nil {
+		set.Headers = &Headers{}
+	}
+	set.Headers.Header = append(set.Headers.Header, Header{Name: name, Value: value})
+	return assignMessage
+}
+
+// NewJSONToXML returns a JSONToXML policy that converts the body of message in place
+func NewJSONToXML(name string, message string) *JSONToXML {
+	return &JSONToXML{
+		Common:         common(name),
+		DisplayName:    name,
+		OutputVariable: message,
+		Source:         message,
+	}
+}
+
+// NewXMLToJSON returns an XMLToJSON policy that converts the body of message in place
+func NewXMLToJSON(name string, message string) *XMLToJSON {
+	return &XMLToJSON{
+		Common:         common(name),
+		DisplayName:    name,
+		OutputVariable: message,
+		Source:         message,
+	}
+}
+
+// NewJavascript returns a Javascript policy that runs the inline source
+func NewJavascript(name string, source string) *Javascript {
+	return &Javascript{
+		Common:      common(name),
+		TimeLimit:   200,
+		DisplayName: name,
+		Source:      source,
+	}
+}
+
+// NewJavascriptResource returns a Javascript policy that runs the jsc resource named resource
+func NewJavascriptResource(name string, resource string) *Javascript {
+	return &Javascript{
+		Common:      common(name),
+		TimeLimit:   200,
+		DisplayName: name,
+		ResourceURL: "jsc://" + resource,
+	}
+}
+
+// NewAccessControl returns an AccessControl policy, noRuleMatchAction is
+// ALLOW or DENY and applies to the addresses no rule matches
+func NewAccessControl(name string, noRuleMatchAction string) *AccessControl {
+	return &AccessControl{
+		Common:      common(name),
+		DisplayName: name,
+		IPRules:     IPRules{NoRuleMatchAction: noRuleMatchAction},
+	}
+}
+
+// AddMatchRule adds a rule with action ALLOW or DENY for the addresses, rules are evaluated in order
+func (accessControl *AccessControl) AddMatchRule(action string, addresses ...SourceAddress) *AccessControl {
+	accessControl.IPRules.MatchRule = append(accessControl.IPRules.MatchRule, MatchRule{Action: action, SourceAddress: addresses})
+	return accessControl
+}
+
+func (assignMessage *AssignMessage) set() *AssignSet {
+	if assignMessage.Set == nil {
+		assignMessage.Set = &AssignSet{}
+	}
+	return assignMessage.Set
+}
+
+// SetStatusCode sets the status code and the reason phrase of a response
+func (assignMessage *AssignMessage) SetStatusCode(statusCode int, reasonPhrase string) *AssignMessage {
+	assignMessage.set().StatusCode = statusCode
+	assignMessage.set().ReasonPhrase = reasonPhrase
+	return assignMessage
+}
+
+// SetJSONPayload sets a JSON body. The braces are kept as they are, flow
+// variables are referenced as @variable# instead.
+func (assignMessage *AssignMessage) SetJSONPayload(payload string) *AssignMessage {
+	assignMessage.set().Payload = &Payload{ContentType: "application/json", VariablePrefix: "@", VariableSuffix: "#", Value: payload}
+	return assignMessage
+}
+
+// NewLookupCache returns a LookupCache policy that reads the entry keyed on
+// the variable keyRef into the variable assignTo
+func NewLookupCache(name string, keyRef string, assignTo string) *LookupCache {
+	return &LookupCache{
+		Common:      common(name),
+		DisplayName: name,
+		CacheKey:    CacheKey{KeyFragment: []Ref{{Ref: keyRef}}},
+		Scope:       "Exclusive",
+		AssignTo:    assignTo,
+	}
+}
+
+// NewPopulateCache returns a PopulateCache policy that stores the variable
+// source keyed on the variable keyRef, for the number of seconds in the
+// variable timeoutRef or timeout seconds when it is not set
+func NewPopulateCache(name string, keyRef string, source string, timeoutRef string, timeout int) *PopulateCache {
+	return &PopulateCache{
+		Common:         common(name),
+		DisplayName:    name,
+		CacheKey:       CacheKey{KeyFragment: []Ref{{Ref: keyRef}}},
+		Scope:          "Exclusive",
+		ExpirySettings: ExpirySettings{TimeoutInSec: Ref{Ref: timeoutRef, Value: strconv.Itoa(timeout)}},
+		Source:         source,
+	}
+}
+
+// Marshal returns the XML of a policy, ready to be written to apiproxy/policies
+func Marshal(policy interface{}) ([]byte, error) {
+	content, err := xml.MarshalIndent(policy, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(header), content...), nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policies
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is passed to the policy templates
+type TemplateData struct {
+	Config    mgconfig.Microgateway
+	ProxyName string
+}
+
+// Templates are user supplied text/template files that replace built-in
+// policies. The template for a policy is named <policy name>.xml.
+type Templates struct {
+	templates map[string]*template.Template
+	used      map[string]bool
+}
+
+// LoadTemplates parses every .xml file in folder
+func LoadTemplates(folder string) (*Templates, error) {
+	files, err := filepath.Glob(filepath.Join(folder, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := &Templates{templates: map[string]*template.Template{}, used: map[string]bool{}}
+	for _, file := range files {
+		tmpl, err := template.ParseFiles(file)
+		if err != nil {
+			return nil, err
+		}
+		templates.templates[strings.TrimSuffix(filepath.Base(file), ".xml")] = tmpl.Option("missingkey=error")
+	}
+	return templates, nil
+}
+
+// Names returns the names of the policies that have a template, sorted
+func (templates *Templates) Names() []string {
+	var names []string
+	for name := range templates.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Unused returns the names of the templates that no generated policy has
+// used, usually a misspelled policy name
+func (templates *Templates) Unused() []string {
+	if templates == nil {
+		return nil
+	}
+	var names []string
+	for name := range templates.templates {
+		if !templates.used[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes the template for a policy. It returns false when there is
+// no template for the policy and the built-in policy should be used.
+func (templates *Templates) Render(policyName string, data TemplateData) ([]byte, bool, error) {
+	if templates == nil {
+		return nil, false, nil
+	}
+	tmpl, ok := templates.templates[policyName]
+	if !ok {
+		return nil, false, nil
+	}
+	templates.used[policyName] = true
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, true, err
+	}
+
+	//the policy is referenced by name from the endpoints, so the template cannot rename it
+	var policy struct {
+		Name string `xml:"name,attr"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &policy); err != nil {
+		return nil, true, fmt.Errorf("template %s.xml does not produce valid XML: %v", policyName, err)
+	}
+	if policy.Name != policyName {
+		return nil, true, fmt.Errorf("template %s.xml produces a policy named %q, expected %q", policyName, policy.Name, policyName)
+	}
+	return buf.Bytes(), true, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policies
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUnusedTemplates(t *testing.T) {
+	folder, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	for _, name := range []string{"Quota-1", "Qouta-1"} {
+		content := `<Quota name="` + name + `"><DisplayName>{{.ProxyName}}</DisplayName></Quota>`
+		if err = ioutil.WriteFile(filepath.Join(folder, name+".xml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	templates, err := LoadTemplates(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := templates.Render("Quota-1", TemplateData{ProxyName: "edgemicro_hello"}); err != nil || !ok {
+		t.Fatalf("rendering Quota-1: %v, %v", ok, err)
+	}
+	if _, ok, _ := templates.Render("Spike-Arrest-1", TemplateData{}); ok {
+		t.Error("a policy without a template was rendered")
+	}
+
+	if names := templates.Names(); !reflect.DeepEqual(names, []string{"Qouta-1", "Quota-1"}) {
+		t.Errorf("templates are %v, expected [Qouta-1 Quota-1]", names)
+	}
+	if unused := templates.Unused(); !reflect.DeepEqual(unused, []string{"Qouta-1"}) {
+		t.Errorf("unused templates are %v, expected [Qouta-1]", unused)
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxyutils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// indentUnit is used when a new element has no siblings to copy indentation from
+const indentUnit string = "    "
+
+// Node is one of *Element, xml.CharData, CData, xml.Comment, xml.ProcInst or xml.Directive
+type Node interface{}
+
+// CData is the text of a <![CDATA[...]]> section. encoding/xml reads it as
+// xml.CharData, it is kept apart so that it is not written back escaped.
+type CData string
+
+// Element is an XML element that keeps its attributes, comments and
+// whitespace exactly as they were read
+type Element struct {
+	Name   xml.Name
+	Attr   []xml.Attr
+	Nodes  []Node
+	parent *Element
+	//expanded is set for empty elements read as <Name></Name> instead of <Name/>
+	expanded bool
+}
+
+// Document is an XML document that can be edited without losing any
+// element or attribute that is not modelled by this package
+type Document struct {
+	Nodes []Node
+	Root  *Element
+}
+
+// ParseDocument reads an XML document, keeping every token
+func ParseDocument(content []byte) (*Document, error) {
+	doc := &Document{}
+	dec := xml.NewDecoder(bytes.NewReader(content))
+
+	var current *Element
+	appendNode := func(node Node) {
+		if current != nil {
+			current.Nodes = append(current.Nodes, node)
+		} else {
+			doc.Nodes = append(doc.Nodes, node)
+		}
+	}
+
+	for {
+		start := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			element := &Element{Name: t.Name, Attr: append([]xml.Attr(nil), t.Attr...), parent: current}
+			offset := dec.InputOffset()
+			element.expanded = offset < 2 || string(content[offset-2:offset]) != "/>"
+			if current == nil {
+				if doc.Root != nil {
+					return nil, fmt.Errorf("more than one root element: <%s>", qualifiedName(t.Name))
+				}
+				doc.Root = element
+			}
+			appendNode(element)
+			current = element
+		case xml.EndElement:
+			if current == nil || current.Name != t.Name {
+				return nil, fmt.Errorf("unexpected end element </%s>", qualifiedName(t.Name))
+			}
+			current = current.parent
+		case xml.CharData:
+			if bytes.HasPrefix(content[start:dec.InputOffset()], []byte("<![CDATA[")) {
+				appendNode(CData(t))
+			} else {
+				appendNode(t.Copy())
+			}
+		case xml.Comment:
+			appendNode(t.Copy())
+		case xml.ProcInst:
+			appendNode(t.Copy())
+		case xml.Directive:
+			appendNode(t.Copy())
+		}
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("element <%s> is not closed", qualifiedName(current.Name))
+	}
+	if doc.Root == nil {
+		return nil, fmt.Errorf("document has no root element")
+	}
+	return doc, nil
+}
+
+// Bytes serializes the document
+func (doc *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, node := range doc.Nodes {
+		writeNode(&buf, node)
+	}
+	return buf.Bytes()
+}
+
+// NewElement creates an element with the given child elements
+func NewElement(name string, children ...*Element) *Element {
+	element := &Element{Name: xml.Name{Local: name}}
+	for _, child := range children {
+		child.parent = element
+		element.Nodes = append(element.Nodes, child)
+	}
+	return element
+}
+
+// NewTextElement creates an element that contains only text
+func NewTextElement(name string, text string) *Element {
+	element := &Element{Name: xml.Name{Local: name}}
+	element.SetText(text)
+	return element
+}
+
+// GetAttr returns the value of an attribute, or "" if it is not set
+func (element *Element) GetAttr(name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// SetAttr sets the value of an attribute, adding it if it is not set
+func (element *Element) SetAttr(name string, value string) *Element {
+	for i, attr := range element.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			element.Attr[i].Value = value
+			return element
+		}
+	}
+	element.Attr = append(element.Attr, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	return element
+}
+
+// Text returns the text content of an element with surrounding whitespace removed
+func (element *Element) Text() string {
+	return strings.TrimSpace(string(xmlText(element.Nodes)))
+}
+
+// SetText replaces the content of an element with text
+func (element *Element) SetText(text string) {
+	element.Nodes = nil
+	if text != "" {
+		element.Nodes = []Node{xml.CharData(text)}
+	}
+}
+
+// Elements returns the child elements with the given name, or all child elements if name is ""
+func (element *Element) Elements(name string) []*Element {
+	var elements []*Element
+	for _, node := range element.Nodes {
+		if child, ok := node.(*Element); ok && (name == "" || child.Name.Local == name) {
+			elements = append(elements, child)
+		}
+	}
+	return elements
+}
+
+// Element returns the first child element with the given name, or nil
+func (element *Element) Element(name string) *Element {
+	elements := element.Elements(name)
+	if len(elements) == 0 {
+		return nil
+	}
+	return elements[0]
+}
+
+// EnsureElement returns the first child element with the given name, creating
+// it if needed. A new element is placed before the first sibling that comes
+// after it in order, so the document keeps the layout Apigee Edge exports.
+func (element *Element) EnsureElement(name string, order ...string) *Element {
+	if child := element.Element(name); child != nil {
+		return child
+	}
+
+	child := NewElement(name)
+	position := indexOf(order, name)
+	if position >= 0 {
+		for i, sibling := range element.Elements("") {
+			if indexOf(order, sibling.Name.Local) > position {
+				element.InsertElement(i, child)
+				return child
+			}
+		}
+	}
+	element.AppendElement(child)
+	return child
+}
+
+// AppendElement adds a child element after the existing child elements
+func (element *Element) AppendElement(child *Element) {
+	element.InsertElement(len(element.Elements("")), child)
+}
+
+// InsertElement adds a child element so that it becomes the index-th child element
+func (element *Element) InsertElement(index int, child *Element) {
+	childIndent := element.childIndent()
+	child.parent = element
+	child.format(childIndent)
+
+	children := element.Elements("")
+	if index < 0 || index > len(children) {
+		index = len(children)
+	}
+
+	if len(children) == 0 {
+		if strings.TrimSpace(string(xmlText(element.Nodes))) != "" {
+			element.Nodes = append(element.Nodes, child)
+			return
+		}
+		element.Nodes = []Node{xml.CharData("\n" + childIndent), child, xml.CharData("\n" + element.indent())}
+		return
+	}
+
+	if index < len(children) {
+		position := element.position(children[index])
+		element.Nodes = insertNodes(element.Nodes, position, child, xml.CharData("\n"+childIndent))
+		return
+	}
+
+	position := element.position(children[len(children)-1]) + 1
+	element.Nodes = insertNodes(element.Nodes, position, xml.CharData("\n"+childIndent), child)
+}
+
+// RemoveElement removes a child element along with the whitespace before it
+func (element *Element) RemoveElement(child *Element) {
+	position := element.position(child)
+	if position < 0 {
+		return
+	}
+	start := position
+	if start > 0 {
+		if text, ok := element.Nodes[start-1].(xml.CharData); ok && strings.TrimSpace(string(text)) == "" {
+			start--
+		}
+	}
+	element.Nodes = append(element.Nodes[:start], element.Nodes[position+1:]...)
+	if len(element.Elements("")) == 0 && strings.TrimSpace(string(xmlText(element.Nodes))) == "" {
+		element.Nodes = nil
+		element.expanded = false
+	}
+	child.parent = nil
+}
+
+func (element *Element) position(child *Element) int {
+	for i, node := range element.Nodes {
+		if node == Node(child) {
+			return i
+		}
+	}
+	return -1
+}
+
+// indent returns the whitespace that precedes the element on its line
+func (element *Element) indent() string {
+	if element.parent == nil {
+		return ""
+	}
+	position := element.parent.position(element)
+	if position > 0 {
+		if text, ok := element.parent.Nodes[position-1].(xml.CharData); ok {
+			if i := strings.LastIndex(string(text), "\n"); i >= 0 && strings.TrimSpace(string(text)) == "" {
+				return string(text[i+1:])
+			}
+		}
+	}
+	return element.parent.indent() + indentUnit
+}
+
+// childIndent returns the whitespace used in front of the child elements
+func (element *Element) childIndent() string {
+	for _, child := range element.Elements("") {
+		position := element.position(child)
+		if position > 0 {
+			if text, ok := element.Nodes[position-1].(xml.CharData); ok {
+				if i := strings.LastIndex(string(text), "\n"); i >= 0 && strings.TrimSpace(string(text)) == "" {
+					return string(text[i+1:])
+				}
+			}
+		}
+	}
+	return element.indent() + indentUnit
+}
+
+// format indents a generated element that has child elements but no whitespace
+func (element *Element) format(indent string) {
+	children := element.Elements("")
+	if len(children) == 0 || len(children) != len(element.Nodes) {
+		return
+	}
+	element.Nodes = nil
+	for _, child := range children {
+		child.parent = element
+		child.format(indent + indentUnit)
+		element.Nodes = append(element.Nodes, xml.CharData("\n"+indent+indentUnit), child)
+	}
+	element.Nodes = append(element.Nodes, xml.CharData("\n"+indent))
+}
+
+func insertNodes(nodes []Node, position int, inserted ...Node) []Node {
+	result := make([]Node, 0, len(nodes)+len(inserted))
+	result = append(result, nodes[:position]...)
+	result = append(result, inserted...)
+	return append(result, nodes[position:]...)
+}
+
+func xmlText(nodes []Node) []byte {
+	var buf bytes.Buffer
+	for _, node := range nodes {
+		switch text := node.(type) {
+		case xml.CharData:
+			buf.Write(text)
+		case CData:
+			buf.WriteString(string(text))
+		}
+	}
+	return buf.Bytes()
+}
+
+func indexOf(list []string, value string) int {
+	for i, item := range list {
+		if item == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+var attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", "\"", "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+
+func writeNode(buf *bytes.Buffer, node Node) {
+	switch n := node.(type) {
+	case *Element:
+		buf.WriteString("<" + qualifiedName(n.Name))
+		for _, attr := range n.Attr {
+			buf.WriteString(" " + qualifiedName(attr.Name) + "=\"" + attrEscaper.Replace(attr.Value) + "\"")
+		}
+		if len(n.Nodes) == 0 && !n.expanded {
+			buf.WriteString("/>")
+			return
+		}
+		buf.WriteString(">")
+		for _, child := range n.Nodes {
+			writeNode(buf, child)
+		}
+		buf.WriteString("</" + qualifiedName(n.Name) + ">")
+	case xml.CharData:
+		buf.WriteString(textEscaper.Replace(string(n)))
+	case CData:
+		//]]> cannot appear in a section, it is split across two
+		buf.WriteString("<![CDATA[" + strings.Replace(string(n), "]]>", "]]]]><![CDATA[>", -1) + "]]>")
+	case xml.Comment:
+		buf.WriteString("<!--" + string(n) + "-->")
+	case xml.ProcInst:
+		buf.WriteString("<?" + n.Target)
+		if len(n.Inst) > 0 {
+			buf.WriteString(" " + string(n.Inst))
+		}
+		buf.WriteString("?>")
+	case xml.Directive:
+		buf.WriteString("<!" + string(n) + ">")
+	}
+}
+
+// EquivalentXML returns true when two documents have the same elements,
+// attributes and text, ignoring whitespace between elements, comments and
+// the XML declaration
+func EquivalentXML(a []byte, b []byte) bool {
+	tokensA, err := significantTokens(a)
+	if err != nil {
+		return false
+	}
+	tokensB, err := significantTokens(b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(tokensA, tokensB)
+}
+
+// significantTokens returns the elements and the text of a document
+func significantTokens(content []byte) ([]xml.Token, error) {
+	var tokens []xml.Token
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			tokens = append(tokens, t.Copy())
+		case xml.EndElement:
+			tokens = append(tokens, t)
+		case xml.CharData:
+			if text := strings.TrimSpace(string(t)); text != "" {
+				tokens = append(tokens, xml.CharData(text))
+			}
+		}
+	}
+}
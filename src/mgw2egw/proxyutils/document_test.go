@@ -0,0 +1,95 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxyutils
+
+import (
+	"testing"
+)
+
+func TestEquivalentXML(t *testing.T) {
+	generated := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Quota async="false" continueOnError="false" enabled="true" name="Quota-1" type="calendar">
+    <DisplayName>Quota-1</DisplayName>
+    <Allow count="200" countRef="apiproduct.developer.quota.limit"></Allow>
+</Quota>`
+	reformatted := `<Quota async="false" continueOnError="false" enabled="true" name="Quota-1" type="calendar">
+  <!-- exported from Apigee Edge -->
+  <DisplayName>Quota-1</DisplayName>
+  <Allow count="200" countRef="apiproduct.developer.quota.limit"/>
+</Quota>`
+	edited := `<Quota async="false" continueOnError="false" enabled="true" name="Quota-1" type="calendar">
+    <DisplayName>Quota-1</DisplayName>
+    <Allow count="5"/>
+</Quota>`
+	if !EquivalentXML([]byte(generated), []byte(reformatted)) {
+		t.Error("a reformatted policy is not equivalent to the generated one")
+	}
+	if EquivalentXML([]byte(generated), []byte(edited)) {
+		t.Error("a policy with another limit is equivalent to the generated one")
+	}
+}
+
+func TestDocumentRoundTrip(t *testing.T) {
+	tests := map[string]string{
+		"comments": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<!-- exported from Apigee Edge -->
+<ProxyEndpoint name="default">
+    <!-- <Step><Name>Disabled-1</Name></Step> -->
+    <Description>a &amp; b</Description>
+</ProxyEndpoint>
+`,
+		"namespaces": `<Policy xmlns="http://www.example.com/policy" xmlns:ext="http://www.example.com/ext" name="Extension-1">
+    <ext:Setting ext:scope="proxy">value</ext:Setting>
+</Policy>`,
+		"cdata": `<Javascript name="Javascript-1">
+    <Source><![CDATA[if (a < b && c > d) { context.setVariable('x', '<y/>'); }]]></Source>
+    <Text>before <![CDATA[<kept>]]> after</Text>
+</Javascript>`,
+		"empty elements": `<AssignMessage name="Assign-1">
+    <Remove/>
+    <Add></Add>
+    <Set>
+    </Set>
+</AssignMessage>`,
+	}
+
+	for name, content := range tests {
+		doc, err := ParseDocument([]byte(content))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got := string(doc.Bytes()); got != content {
+			t.Errorf("%s: round trip changed the document\n%s\nexpected\n%s", name, got, content)
+		}
+	}
+}
+
+func TestCDataEdit(t *testing.T) {
+	doc, err := ParseDocument([]byte(`<Javascript name="Javascript-1"><Source><![CDATA[a < b]]></Source></Javascript>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := doc.Root.Element("Source")
+	if text := source.Text(); text != "a < b" {
+		t.Errorf("text of a CDATA section is %q, expected %q", text, "a < b")
+	}
+
+	source.Nodes = []Node{CData("x = ']]>';")}
+	expected := `<Javascript name="Javascript-1"><Source><![CDATA[x = ']]]]><![CDATA[>';]]></Source></Javascript>`
+	if got := string(doc.Bytes()); got != expected {
+		t.Errorf("CDATA containing ]]> is written as\n%s\nexpected\n%s", got, expected)
+	}
+}
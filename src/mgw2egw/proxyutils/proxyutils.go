@@ -15,165 +15,497 @@
 package proxyutils
 
 import (
-	"encoding/xml"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 )
 
+// markerPattern matches the marker left in the APIProxy Description by a previous conversion
+var markerPattern = regexp.MustCompile(`\s*\[mgw2egw fingerprint=([0-9a-f]*) policies=([^\]\s]*)(?: flows=([^\]\s]*))?(?: faultrules=([^\]\s]*))?\]`)
+
+// proxyEndpointOrder is the order of the ProxyEndpoint elements in a bundle exported from Apigee Edge
+var proxyEndpointOrder = []string{"Description", "FaultRules", "DefaultFaultRule", "PreFlow", "PostFlow", "Flows", "HTTPProxyConnection", "RouteRule"}
+
+// targetEndpointOrder is the order of the TargetEndpoint elements in a bundle exported from Apigee Edge
+var targetEndpointOrder = []string{"Description", "FaultRules", "DefaultFaultRule", "PreFlow", "PostFlow", "Flows", "HTTPTargetConnection", "LocalTargetConnection", "ScriptTarget"}
+
+// apiProxyOrder is the order of the APIProxy elements in a bundle exported from Apigee Edge
+var apiProxyOrder = []string{"Basepaths", "ConfigurationVersion", "CreatedAt", "CreatedBy", "Description", "DisplayName", "LastModifiedAt", "LastModifiedBy", "Policies", "ProxyEndpoints", "Resources", "Spec", "TargetServers", "TargetEndpoints", "validate"}
+
+// DefaultFaultRule is the Flow of the steps attached to the DefaultFaultRule
+// of an endpoint, which runs them whenever a fault is raised
+const DefaultFaultRule string = "DefaultFaultRule"
+
+// flowOrder is the order of the elements of a flow
+var flowOrder = []string{"Description", "Request", "Response", "Condition"}
+
+// ProxyEndpoint is a ProxyEndpoint configuration file. Only the elements
+// touched by the conversion are changed, the rest of the document is
+// written back as it was read.
 type ProxyEndpoint struct {
-	XMLName             xml.Name            `xml:"ProxyEndpoint"`
-	Name                string              `xml:"name,attr"`
-	Description         string              `xml:"Description,omitempty"`
-	FaultRules          string              `xml:"FaultRules,omitempty"`
-	PreFlow             PreFlow             `xml:"PreFlow,omitempty"`
-	PostFlow            PostFlow            `xml:"PostFlow,omitempty"`
-	HTTPProxyConnection HTTPProxyConnection `xml:"HTTPProxyConnection,omitempty"`
-	RouteRule           RouteRule           `xml:"RouteRule,omitempty"`
+	*Document
 }
 
-type PreFlow struct {
-	XMLName  xml.Name `xml:"PreFlow"`
-	Name     string   `xml:"name,attr"`
-	Request  Request  `xml:"Request,omitempty"`
-	Response Response `xml:"Response,omitempty"`
+// TargetEndpoint is a TargetEndpoint configuration file, edited the same way as ProxyEndpoint
+type TargetEndpoint struct {
+	*Document
 }
 
-type Request struct {
-	XMLName xml.Name `xml:"Request,omitempty"`
-	Step    []Step   `xml:"Step,omitempty"`
+// APIProxy is the APIProxy descriptor of a bundle
+type APIProxy struct {
+	*Document
 }
 
-type Step struct {
-	Name string `xml:"Name,omitempty"`
+func writeDocument(doc *Document, fileName string) error {
+	fileWriter, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer fileWriter.Close()
+	_, err = fileWriter.Write(doc.Bytes())
+	return err
 }
 
-type Response struct {
-	XMLName xml.Name `xml:"Response,omitempty"`
-	Step    []Step   `xml:"Step,omitempty"`
+func readDocument(fileName string, rootName string) (*Document, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ParseDocument(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	if doc.Root.Name.Local != rootName {
+		return nil, fmt.Errorf("%s: expected <%s>, found <%s>", fileName, rootName, doc.Root.Name.Local)
+	}
+	return doc, nil
 }
 
-type PostFlow struct {
-	XMLName  xml.Name `xml:"PostFlow"`
-	Name     string   `xml:"name,attr"`
-	Request  Request  `xml:"Request,omitempty"`
-	Response Response `xml:"Response,omitempty"`
+func WriteAPIProxy(apiProxy APIProxy, fileName string) error {
+	return writeDocument(apiProxy.Document, fileName)
 }
 
-type HTTPProxyConnection struct {
-	XMLName     xml.Name `xml:"HTTPProxyConnection"`
-	BasePath    string   `xml:"BasePath"`
-	Properties  string   `xml:"Properties"`
-	VirtualHost []string `xml:"VirtualHost"`
+func WriteProxyEndpoint(proxyEndpoint ProxyEndpoint, fileName string) error {
+	return writeDocument(proxyEndpoint.Document, fileName)
 }
 
-type RouteRule struct {
-	XMLName        xml.Name `xml:"RouteRule"`
-	TargetEndpoint string   `xml:"TargetEndpoint"`
+func WriteTargetEndpoint(targetEndpoint TargetEndpoint, fileName string) error {
+	return writeDocument(targetEndpoint.Document, fileName)
 }
 
-type APIProxy struct {
-	XMLName              xml.Name             `xml:"APIProxy"`
-	Name                 string               `xml:"name,attr"`
-	Revision             string               `xml:"revision,attr"`
-	Basepaths            string               `xml:"Basepaths,omitempty"`
-	ConfigurationVersion ConfigurationVersion `xml:"ConfigurationVersion,omitempty"`
-	CreatedAt            string               `xml:"CreatedAt,omitempty"`
-	CreatedBy            string               `xml:"CreatedBy,omitempty"`
-	Description          string               `xml:"Description,omitempty"`
-	DisplayName          string               `xml:"DisplayName,omitempty"`
-	LastModifiedAt       string               `xml:"LastModifiedAt,omitempty"`
-	LastModifiedBy       string               `xml:"LastModifiedBy,omitempty"`
-	Policies             Policies             `xml:"Policies,omitempty"`
-	ProxyEndpoints       ProxyEndpoints       `xml:"ProxyEndpoints,omitempty"`
-	Resources            string               `xml:"Resources,omitempty"`
-	Spec                 string               `xml:"Spec,omitempty"`
-	TargetServers        string               `xml:"TargetServers,omitempty"`
-	TargetEndpoints      TargetEndpoints      `xml:"TargetEndpoints,omitempty"`
-	Validate             string               `xml:"validate,omitempty"`
+// Step attaches a policy to a flow of an endpoint
+type Step struct {
+	Policy string
+	//Flow is PreFlow (when empty), PostFlow or the name of a conditional flow
+	Flow string
+	//Response attaches the policy to the response instead of the request
+	Response  bool
+	Condition string
+	//First places the step ahead of the steps already in the flow
+	First bool
 }
 
-type ConfigurationVersion struct {
-	XMLName      xml.Name `xml:"ConfigurationVersion,omitempty"`
-	MajorVersion string   `xml:"majorVersion,attr"`
-	MinorVersion string   `xml:"minorVersion,attr"`
+// Flow is a conditional flow
+type Flow struct {
+	Name      string
+	Condition string
 }
 
-type Policies struct {
-	XMLName xml.Name `xml:"Policies"`
-	Policy  []string `xml:"Policy,omitempty"`
+// RouteRule sends the requests that match Condition to TargetEndpoint, or
+// answers them from the proxy when TargetEndpoint is ""
+type RouteRule struct {
+	Name           string
+	Condition      string
+	TargetEndpoint string
 }
 
-type ProxyEndpoints struct {
-	XMLName       xml.Name `xml:"ProxyEndpoints"`
-	ProxyEndpoint []string `xml:"ProxyEndpoint,omitempty"`
+// getFlow returns the PreFlow, the PostFlow or the conditional flow of an
+// endpoint with the given name. PreFlow and PostFlow are created if needed.
+func getFlow(endpoint *Element, order []string, name string) (*Element, error) {
+	if name == "" {
+		name = "PreFlow"
+	}
+	if name == "PreFlow" || name == "PostFlow" {
+		flow := endpoint.Element(name)
+		if flow == nil {
+			flow = endpoint.EnsureElement(name, order...)
+			flow.SetAttr("name", name)
+		}
+		return flow, nil
+	}
+	if flows := endpoint.Element("Flows"); flows != nil {
+		for _, flow := range flows.Elements("Flow") {
+			if flow.GetAttr("name") == name {
+				return flow, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("<%s> has no flow named %s", endpoint.Name.Local, name)
 }
 
-type TargetEndpoints struct {
-	XMLName        xml.Name `xml:"TargetEndpoints"`
-	TargetEndpoint []string `xml:"TargetEndpoint,omitempty"`
+// addSteps appends each step to the request or response of its flow. Steps
+// marked First are inserted ahead of the existing steps, in the order given.
+func addSteps(endpoint *Element, order []string, steps []Step) error {
+	first := map[*Element]int{}
+	for _, step := range steps {
+		if step.Flow == DefaultFaultRule {
+			addFaultRuleStep(endpoint, order, step)
+			continue
+		}
+		flow, err := getFlow(endpoint, order, step.Flow)
+		if err != nil {
+			return err
+		}
+		phase := "Request"
+		if step.Response {
+			phase = "Response"
+		}
+		children := []*Element{NewTextElement("Name", step.Policy)}
+		if step.Condition != "" {
+			children = append(children, NewTextElement("Condition", step.Condition))
+		}
+		list := flow.EnsureElement(phase, flowOrder...)
+		if step.First {
+			list.InsertElement(first[list], NewElement("Step", children...))
+			first[list]++
+			continue
+		}
+		list.AppendElement(NewElement("Step", children...))
+	}
+	return nil
 }
 
-func WriteAPIProxy(apiProxy APIProxy, fileName string) error {
-	fileWriter, err := os.Create(fileName)
-	if err != nil {
-		return err
+// PlaceSteps orders the steps of the plugins the way microgateway runs
+// them: the request steps in the order of the plugins, then the response
+// steps in the reverse order, each plugin keeping the order of its own steps.
+// pluginSteps holds the steps of each plugin in the order of the sequence.
+func PlaceSteps(pluginSteps ...[]Step) []Step {
+	var steps []Step
+	for _, plugin := range pluginSteps {
+		for _, step := range plugin {
+			if !step.Response {
+				steps = append(steps, step)
+			}
+		}
 	}
-	enc := xml.NewEncoder(fileWriter)
-	err = enc.Encode(apiProxy)
-	if err != nil {
-		return err
+	for i := len(pluginSteps) - 1; i >= 0; i-- {
+		for _, step := range pluginSteps[i] {
+			if step.Response {
+				steps = append(steps, step)
+			}
+		}
 	}
-	defer fileWriter.Close()
-	return nil
+	return steps
 }
 
-func WriteProxyEndpoint(proxyEndpoint ProxyEndpoint, fileName string) error {
-	fileWriter, err := os.Create(fileName)
-	if err != nil {
-		return err
+// addFaultRuleStep appends a step to the DefaultFaultRule of an endpoint.
+// A DefaultFaultRule created here sets AlwaysEnforce so the step also runs
+// after the FaultRules, an existing one keeps its own AlwaysEnforce.
+func addFaultRuleStep(endpoint *Element, order []string, step Step) {
+	rule := endpoint.Element(DefaultFaultRule)
+	if rule == nil {
+		rule = endpoint.EnsureElement(DefaultFaultRule, order...)
+		rule.SetAttr("name", "default-fault")
+		rule.AppendElement(NewTextElement("AlwaysEnforce", "true"))
 	}
-	enc := xml.NewEncoder(fileWriter)
-	err = enc.Encode(proxyEndpoint)
-	if err != nil {
-		return err
+	children := []*Element{NewTextElement("Name", step.Policy)}
+	if step.Condition != "" {
+		children = append(children, NewTextElement("Condition", step.Condition))
 	}
-	defer fileWriter.Close()
-	return nil
+	//steps come before AlwaysEnforce and Condition
+	position := 0
+	for _, child := range rule.Elements("") {
+		if child.Name.Local != "Step" {
+			break
+		}
+		position++
+	}
+	rule.InsertElement(position, NewElement("Step", children...))
 }
 
-func AddPolicyProxyEndpoint(proxyEndpoint ProxyEndpoint, policyNames ...string) ProxyEndpoint {
+// HasDefaultFaultRule returns true when an endpoint has a DefaultFaultRule
+func HasDefaultFaultRule(endpoint *Document) bool {
+	return endpoint.Root.Element(DefaultFaultRule) != nil
+}
 
+// RemoveDefaultFaultRule removes the DefaultFaultRule of an endpoint once it
+// has no steps left. It is only called for a DefaultFaultRule added by a
+// previous conversion.
+func RemoveDefaultFaultRule(endpoint *Document) {
+	if rule := endpoint.Root.Element(DefaultFaultRule); rule != nil && len(rule.Elements("Step")) == 0 {
+		endpoint.Root.RemoveElement(rule)
+	}
+}
+
+func preFlowSteps(policyNames []string) []Step {
+	var steps []Step
 	for _, policyName := range policyNames {
-		step := new(Step)
-		step.Name = policyName
-		proxyEndpoint.PreFlow.Request.Step = append(proxyEndpoint.PreFlow.Request.Step, *step)
+		steps = append(steps, Step{Policy: policyName})
+	}
+	return steps
+}
+
+// AddPolicyProxyEndpoint appends the policies to the PreFlow request, use
+// AddStepsProxyEndpoint to attach them to other flows
+func AddPolicyProxyEndpoint(proxyEndpoint ProxyEndpoint, policyNames ...string) ProxyEndpoint {
+	addSteps(proxyEndpoint.Root, proxyEndpointOrder, preFlowSteps(policyNames))
+	return proxyEndpoint
+}
+
+// AddPolicyTargetEndpoint appends the policies to the PreFlow request, use
+// AddStepsTargetEndpoint to attach them to other flows
+func AddPolicyTargetEndpoint(targetEndpoint TargetEndpoint, policyNames ...string) TargetEndpoint {
+	addSteps(targetEndpoint.Root, targetEndpointOrder, preFlowSteps(policyNames))
+	return targetEndpoint
+}
+
+// AddStepsProxyEndpoint attaches policies to the flows of a ProxyEndpoint.
+// Conditional flows must be added before their steps.
+func AddStepsProxyEndpoint(proxyEndpoint ProxyEndpoint, steps ...Step) (ProxyEndpoint, error) {
+	return proxyEndpoint, addSteps(proxyEndpoint.Root, proxyEndpointOrder, steps)
+}
+
+// AddStepsTargetEndpoint attaches policies to the flows of a TargetEndpoint
+func AddStepsTargetEndpoint(targetEndpoint TargetEndpoint, steps ...Step) (TargetEndpoint, error) {
+	return targetEndpoint, addSteps(targetEndpoint.Root, targetEndpointOrder, steps)
+}
+
+// AddFlowsProxyEndpoint adds conditional flows ahead of the existing flows,
+// so they are evaluated first
+func AddFlowsProxyEndpoint(proxyEndpoint ProxyEndpoint, flows ...Flow) ProxyEndpoint {
+	if len(flows) == 0 {
+		return proxyEndpoint
+	}
+	list := proxyEndpoint.Root.EnsureElement("Flows", proxyEndpointOrder...)
+	for i, flow := range flows {
+		element := NewElement("Flow", NewElement("Description"), NewElement("Request"), NewElement("Response"), NewTextElement("Condition", flow.Condition))
+		list.InsertElement(i, element.SetAttr("name", flow.Name))
+	}
+	return proxyEndpoint
+}
+
+// AddRouteRulesProxyEndpoint adds route rules ahead of the existing route
+// rules, so they are evaluated first
+func AddRouteRulesProxyEndpoint(proxyEndpoint ProxyEndpoint, routeRules ...RouteRule) ProxyEndpoint {
+	root := proxyEndpoint.Root
+	position := len(root.Elements(""))
+	for i, child := range root.Elements("") {
+		if child.Name.Local == "RouteRule" {
+			position = i
+			break
+		}
+	}
+	for i, routeRule := range routeRules {
+		var children []*Element
+		if routeRule.Condition != "" {
+			children = append(children, NewTextElement("Condition", routeRule.Condition))
+		}
+		if routeRule.TargetEndpoint != "" {
+			children = append(children, NewTextElement("TargetEndpoint", routeRule.TargetEndpoint))
+		}
+		root.InsertElement(position+i, NewElement("RouteRule", children...).SetAttr("name", routeRule.Name))
+	}
+	return proxyEndpoint
+}
+
+// RemoveFlowsProxyEndpoint removes the conditional flows and the route rules with the given names
+func RemoveFlowsProxyEndpoint(proxyEndpoint ProxyEndpoint, names ...string) ProxyEndpoint {
+	if flows := proxyEndpoint.Root.Element("Flows"); flows != nil {
+		for _, flow := range flows.Elements("Flow") {
+			if indexOf(names, flow.GetAttr("name")) >= 0 {
+				flows.RemoveElement(flow)
+			}
+		}
+	}
+	for _, routeRule := range proxyEndpoint.Root.Elements("RouteRule") {
+		if indexOf(names, routeRule.GetAttr("name")) >= 0 {
+			proxyEndpoint.Root.RemoveElement(routeRule)
+		}
 	}
 	return proxyEndpoint
 }
 
 func AddPolicyAPIProxy(apiProxy APIProxy, policyNames ...string) APIProxy {
+	policies := apiProxy.Root.EnsureElement("Policies", apiProxyOrder...)
 	for _, policyName := range policyNames {
-		apiProxy.Policies.Policy = append(apiProxy.Policies.Policy, policyName)
+		policies.AppendElement(NewTextElement("Policy", policyName))
+	}
+	return apiProxy
+}
+
+// GetPolicies returns the policies listed in the APIProxy descriptor
+func GetPolicies(apiProxy APIProxy) []string {
+	var policyNames []string
+	if policies := apiProxy.Root.Element("Policies"); policies != nil {
+		for _, policy := range policies.Elements("Policy") {
+			policyNames = append(policyNames, policy.Text())
+		}
+	}
+	return policyNames
+}
+
+func RemovePolicyAPIProxy(apiProxy APIProxy, policyNames ...string) APIProxy {
+	if policies := apiProxy.Root.Element("Policies"); policies != nil {
+		for _, policy := range policies.Elements("Policy") {
+			if indexOf(policyNames, policy.Text()) >= 0 {
+				policies.RemoveElement(policy)
+			}
+		}
+	}
+	return apiProxy
+}
+
+// AddResourceAPIProxy lists resources such as jsc://name.js in the APIProxy descriptor
+func AddResourceAPIProxy(apiProxy APIProxy, resources ...string) APIProxy {
+	list := apiProxy.Root.EnsureElement("Resources", apiProxyOrder...)
+	for _, resource := range resources {
+		list.AppendElement(NewTextElement("Resource", resource))
+	}
+	return apiProxy
+}
+
+func RemoveResourceAPIProxy(apiProxy APIProxy, resources ...string) APIProxy {
+	if list := apiProxy.Root.Element("Resources"); list != nil {
+		for _, resource := range list.Elements("Resource") {
+			if indexOf(resources, resource.Text()) >= 0 {
+				list.RemoveElement(resource)
+			}
+		}
+	}
+	return apiProxy
+}
+
+// removeSteps removes the steps that run one of the policies, wherever they are attached
+func removeSteps(element *Element, policyNames []string) {
+	for _, child := range element.Elements("") {
+		if child.Name.Local == "Step" {
+			if name := child.Element("Name"); name != nil && indexOf(policyNames, name.Text()) >= 0 {
+				element.RemoveElement(child)
+				continue
+			}
+		}
+		removeSteps(child, policyNames)
+	}
+}
+
+func RemovePolicyProxyEndpoint(proxyEndpoint ProxyEndpoint, policyNames ...string) ProxyEndpoint {
+	removeSteps(proxyEndpoint.Root, policyNames)
+	return proxyEndpoint
+}
+
+func RemovePolicyTargetEndpoint(targetEndpoint TargetEndpoint, policyNames ...string) TargetEndpoint {
+	removeSteps(targetEndpoint.Root, policyNames)
+	return targetEndpoint
+}
+
+// GetConversionMarker returns the fingerprint, the policies, the
+// conditional flows and the endpoints given a DefaultFaultRule recorded in
+// the APIProxy Description by a previous conversion, or "" if the proxy was
+// never converted
+func GetConversionMarker(apiProxy APIProxy) (string, []string, []string, []string) {
+	description := apiProxy.Root.Element("Description")
+	if description == nil {
+		return "", nil, nil, nil
+	}
+	match := markerPattern.FindStringSubmatch(description.Text())
+	if match == nil {
+		return "", nil, nil, nil
+	}
+	return match[1], splitList(match[2]), splitList(match[3]), splitList(match[4])
+}
+
+// SetConversionMarker records the fingerprint of a conversion, the policies
+// and the conditional flows it added, and the endpoints it gave a
+// DefaultFaultRule (as proxies/<name> or targets/<name>) in the APIProxy
+// Description, replacing an older marker
+func SetConversionMarker(apiProxy APIProxy, fingerprint string, policyNames []string, flowNames []string, faultRuleEndpoints []string) APIProxy {
+	description := apiProxy.Root.EnsureElement("Description", apiProxyOrder...)
+	text := markerPattern.ReplaceAllString(description.Text(), "")
+	marker := "[mgw2egw fingerprint=" + fingerprint + " policies=" + strings.Join(policyNames, ",")
+	if len(flowNames) > 0 {
+		marker += " flows=" + strings.Join(flowNames, ",")
+	}
+	if len(faultRuleEndpoints) > 0 {
+		marker += " faultrules=" + strings.Join(faultRuleEndpoints, ",")
 	}
+	description.SetText(strings.TrimSpace(text + " " + marker + "]"))
 	return apiProxy
 }
 
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
 func ReadProxyEndpoint(fileName string) (ProxyEndpoint, error) {
-	var proxyEndpoint ProxyEndpoint
-	content, err := ioutil.ReadFile(fileName)
-	err = xml.Unmarshal(content, &proxyEndpoint)
+	doc, err := readDocument(fileName, "ProxyEndpoint")
+	if err != nil {
+		return ProxyEndpoint{}, err
+	}
+	return ProxyEndpoint{doc}, nil
+}
+
+func ReadTargetEndpoint(fileName string) (TargetEndpoint, error) {
+	doc, err := readDocument(fileName, "TargetEndpoint")
 	if err != nil {
-		return proxyEndpoint, err
+		return TargetEndpoint{}, err
 	}
-	return proxyEndpoint, nil
+	return TargetEndpoint{doc}, nil
 }
 
 func ReadAPIProxy(fileName string) (APIProxy, error) {
-	var apiProxy APIProxy
-	content, err := ioutil.ReadFile(fileName)
-	err = xml.Unmarshal(content, &apiProxy)
+	doc, err := readDocument(fileName, "APIProxy")
+	if err != nil {
+		return APIProxy{}, err
+	}
+	return APIProxy{doc}, nil
+}
+
+// GetProxyName returns the name of the proxy in a bundle folder, taken
+// from the APIProxy descriptor in apiproxy/
+func GetProxyName(bundleFolder string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(bundleFolder, "apiproxy", "*.xml"))
 	if err != nil {
-		return apiProxy, err
+		return "", err
+	}
+	if len(files) != 1 {
+		return "", fmt.Errorf("expected one APIProxy descriptor in %s, found %d", filepath.Join(bundleFolder, "apiproxy"), len(files))
+	}
+	return strings.TrimSuffix(filepath.Base(files[0]), ".xml"), nil
+}
+
+// GetProxyEndpoints returns the ProxyEndpoints listed in the APIProxy
+// descriptor. If the descriptor does not list any, the files in the proxies
+// folder are used instead.
+func GetProxyEndpoints(apiProxy APIProxy, proxiesFolder string) []string {
+	return getEndpoints(apiProxy, "ProxyEndpoints", "ProxyEndpoint", proxiesFolder)
+}
+
+// GetTargetEndpoints returns the TargetEndpoints listed in the APIProxy
+// descriptor, or the files in the targets folder
+func GetTargetEndpoints(apiProxy APIProxy, targetsFolder string) []string {
+	return getEndpoints(apiProxy, "TargetEndpoints", "TargetEndpoint", targetsFolder)
+}
+
+func getEndpoints(apiProxy APIProxy, listName string, itemName string, folder string) []string {
+	var endpoints []string
+	if list := apiProxy.Root.Element(listName); list != nil {
+		for _, endpoint := range list.Elements(itemName) {
+			endpoints = append(endpoints, endpoint.Text())
+		}
+	}
+	if len(endpoints) > 0 {
+		return endpoints
+	}
+
+	files, _ := filepath.Glob(filepath.Join(folder, "*.xml"))
+	for _, file := range files {
+		endpoints = append(endpoints, strings.TrimSuffix(filepath.Base(file), ".xml"))
 	}
-	return apiProxy, nil
+	return endpoints
 }
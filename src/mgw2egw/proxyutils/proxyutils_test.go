@@ -0,0 +1,307 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxyutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+// parseProxyEndpoint parses a ProxyEndpoint configuration file
+func parseProxyEndpoint(t *testing.T, content string) ProxyEndpoint {
+	doc, err := ParseDocument([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ProxyEndpoint{doc}
+}
+
+func TestAddStepsDefaultFaultRule(t *testing.T) {
+	proxyEndpoint := parseProxyEndpoint(t, `<ProxyEndpoint name="default">
+    <Description/>
+    <FaultRules/>
+    <PreFlow name="PreFlow">
+        <Request/>
+        <Response/>
+    </PreFlow>
+</ProxyEndpoint>`)
+	proxyEndpoint, err := AddStepsProxyEndpoint(proxyEndpoint,
+		Step{Policy: "Add-CORS-1", Flow: "PostFlow", Response: true},
+		Step{Policy: "Add-CORS-1", Flow: DefaultFaultRule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<ProxyEndpoint name="default">
+    <Description/>
+    <FaultRules/>
+    <DefaultFaultRule name="default-fault">
+        <Step>
+            <Name>Add-CORS-1</Name>
+        </Step>
+        <AlwaysEnforce>true</AlwaysEnforce>
+    </DefaultFaultRule>
+    <PreFlow name="PreFlow">
+        <Request/>
+        <Response/>
+    </PreFlow>
+    <PostFlow name="PostFlow">
+        <Response>
+            <Step>
+                <Name>Add-CORS-1</Name>
+            </Step>
+        </Response>
+    </PostFlow>
+</ProxyEndpoint>`
+	if got := string(proxyEndpoint.Bytes()); got != want {
+		t.Errorf("ProxyEndpoint =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAddStepsExistingDefaultFaultRule(t *testing.T) {
+	proxyEndpoint := parseProxyEndpoint(t, `<ProxyEndpoint name="default">
+    <DefaultFaultRule name="all">
+        <Step>
+            <Name>Log-Error</Name>
+        </Step>
+        <AlwaysEnforce>false</AlwaysEnforce>
+    </DefaultFaultRule>
+</ProxyEndpoint>`)
+	proxyEndpoint, err := AddStepsProxyEndpoint(proxyEndpoint, Step{Policy: "Add-CORS-1", Flow: DefaultFaultRule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<ProxyEndpoint name="default">
+    <DefaultFaultRule name="all">
+        <Step>
+            <Name>Log-Error</Name>
+        </Step>
+        <Step>
+            <Name>Add-CORS-1</Name>
+        </Step>
+        <AlwaysEnforce>false</AlwaysEnforce>
+    </DefaultFaultRule>
+</ProxyEndpoint>`
+	if got := string(proxyEndpoint.Bytes()); got != want {
+		t.Errorf("ProxyEndpoint =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRemoveDefaultFaultRule(t *testing.T) {
+	original := `<ProxyEndpoint name="default">
+    <Description/>
+    <FaultRules/>
+    <PreFlow name="PreFlow">
+        <Request/>
+        <Response/>
+    </PreFlow>
+</ProxyEndpoint>`
+	proxyEndpoint := parseProxyEndpoint(t, original)
+	if HasDefaultFaultRule(proxyEndpoint.Document) {
+		t.Fatal("the ProxyEndpoint has a DefaultFaultRule before the conversion")
+	}
+	proxyEndpoint, err := AddStepsProxyEndpoint(proxyEndpoint, Step{Policy: "Add-CORS-1", Flow: DefaultFaultRule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !HasDefaultFaultRule(proxyEndpoint.Document) {
+		t.Fatal("the conversion did not add a DefaultFaultRule")
+	}
+
+	//a re-run without cors removes the step, then the rule it created
+	proxyEndpoint = RemovePolicyProxyEndpoint(proxyEndpoint, "Add-CORS-1")
+	RemoveDefaultFaultRule(proxyEndpoint.Document)
+	if got := string(proxyEndpoint.Bytes()); got != original {
+		t.Errorf("ProxyEndpoint =\n%s\nwant\n%s", got, original)
+	}
+}
+
+func TestRemoveDefaultFaultRuleKeepsSteps(t *testing.T) {
+	original := `<ProxyEndpoint name="default">
+    <DefaultFaultRule name="all">
+        <Step>
+            <Name>Log-Error</Name>
+        </Step>
+        <AlwaysEnforce>false</AlwaysEnforce>
+    </DefaultFaultRule>
+</ProxyEndpoint>`
+	proxyEndpoint := parseProxyEndpoint(t, original)
+	RemoveDefaultFaultRule(proxyEndpoint.Document)
+	if got := string(proxyEndpoint.Bytes()); got != original {
+		t.Errorf("ProxyEndpoint =\n%s\nwant\n%s", got, original)
+	}
+}
+
+func TestConversionMarkerFaultRules(t *testing.T) {
+	doc, err := ParseDocument([]byte(`<APIProxy name="edgemicro_hello"><Description>hello</Description></APIProxy>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiProxy := SetConversionMarker(APIProxy{doc}, "abc", []string{"Add-CORS-1"}, nil, []string{"proxies/default", "targets/default"})
+	fingerprint, policyNames, flowNames, faultRules := GetConversionMarker(apiProxy)
+	if fingerprint != "abc" || len(policyNames) != 1 || len(flowNames) != 0 || len(faultRules) != 2 || faultRules[1] != "targets/default" {
+		t.Errorf("marker is %q %v %v %v", fingerprint, policyNames, flowNames, faultRules)
+	}
+}
+
+// pluginSteps are the steps of three plugins in the order of the sequence
+var pluginSteps = [][]Step{
+	{
+		{Policy: "Verify-API-Key-1"},
+		{Policy: "Remove-Authorization-1", Response: true, Flow: "PostFlow"},
+	},
+	{
+		{Policy: "Add-CORS-1", Flow: "PostFlow", Response: true},
+		{Policy: "Add-CORS-1", Flow: DefaultFaultRule},
+		{Policy: "Assign-Preflight-1", Flow: "CORS-Preflight"},
+	},
+	{
+		{Policy: "Spike-Arrest-1", First: true},
+		{Policy: "Set-Response-Time-1", Flow: "PostFlow", Response: true},
+	},
+}
+
+func TestPlaceSteps(t *testing.T) {
+	var got []string
+	for _, step := range PlaceSteps(pluginSteps...) {
+		got = append(got, step.Policy+"@"+step.Flow)
+	}
+	want := []string{
+		"Verify-API-Key-1@",
+		"Add-CORS-1@" + DefaultFaultRule,
+		"Assign-Preflight-1@CORS-Preflight",
+		"Spike-Arrest-1@",
+		"Set-Response-Time-1@PostFlow",
+		"Add-CORS-1@PostFlow",
+		"Remove-Authorization-1@PostFlow",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("steps =\n%v\nwant\n%v", got, want)
+	}
+}
+
+// placedEndpoint is the ProxyEndpoint used by the PlaceSteps tests, with a
+// step and a conditional flow of its own
+const placedEndpoint = `<ProxyEndpoint name="default">
+    <PreFlow name="PreFlow">
+        <Request>
+            <Step>
+                <Name>Log-Request</Name>
+            </Step>
+        </Request>
+        <Response/>
+    </PreFlow>
+    <PostFlow name="PostFlow">
+        <Response>
+            <Step>
+                <Name>Log-Response</Name>
+            </Step>
+        </Response>
+    </PostFlow>
+    <Flows>
+        <Flow name="CORS-Preflight">
+            <Request/>
+            <Condition>request.verb = "OPTIONS"</Condition>
+        </Flow>
+    </Flows>
+</ProxyEndpoint>`
+
+func TestPlaceStepsInFlows(t *testing.T) {
+	proxyEndpoint := parseProxyEndpoint(t, placedEndpoint)
+	proxyEndpoint, err := AddStepsProxyEndpoint(proxyEndpoint, PlaceSteps(pluginSteps...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<ProxyEndpoint name="default">
+    <DefaultFaultRule name="default-fault">
+        <Step>
+            <Name>Add-CORS-1</Name>
+        </Step>
+        <AlwaysEnforce>true</AlwaysEnforce>
+    </DefaultFaultRule>
+    <PreFlow name="PreFlow">
+        <Request>
+            <Step>
+                <Name>Spike-Arrest-1</Name>
+            </Step>
+            <Step>
+                <Name>Log-Request</Name>
+            </Step>
+            <Step>
+                <Name>Verify-API-Key-1</Name>
+            </Step>
+        </Request>
+        <Response/>
+    </PreFlow>
+    <PostFlow name="PostFlow">
+        <Response>
+            <Step>
+                <Name>Log-Response</Name>
+            </Step>
+            <Step>
+                <Name>Set-Response-Time-1</Name>
+            </Step>
+            <Step>
+                <Name>Add-CORS-1</Name>
+            </Step>
+            <Step>
+                <Name>Remove-Authorization-1</Name>
+            </Step>
+        </Response>
+    </PostFlow>
+    <Flows>
+        <Flow name="CORS-Preflight">
+            <Request>
+                <Step>
+                    <Name>Assign-Preflight-1</Name>
+                </Step>
+            </Request>
+            <Condition>request.verb = "OPTIONS"</Condition>
+        </Flow>
+    </Flows>
+</ProxyEndpoint>`
+	if got := string(proxyEndpoint.Bytes()); got != want {
+		t.Errorf("ProxyEndpoint =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPlaceStepsRerun(t *testing.T) {
+	steps := PlaceSteps(pluginSteps...)
+	var policyNames []string
+	for _, step := range steps {
+		policyNames = append(policyNames, step.Policy)
+	}
+
+	proxyEndpoint := parseProxyEndpoint(t, placedEndpoint)
+	proxyEndpoint, err := AddStepsProxyEndpoint(proxyEndpoint, steps...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted := string(proxyEndpoint.Bytes())
+
+	//a re-run removes the steps of the previous conversion before it adds
+	//them again, so the steps are not duplicated and keep their places
+	proxyEndpoint = RemovePolicyProxyEndpoint(proxyEndpoint, policyNames...)
+	RemoveDefaultFaultRule(proxyEndpoint.Document)
+	if got := string(proxyEndpoint.Bytes()); got != placedEndpoint {
+		t.Errorf("ProxyEndpoint after removing the steps =\n%s\nwant\n%s", got, placedEndpoint)
+	}
+	proxyEndpoint, err = AddStepsProxyEndpoint(proxyEndpoint, steps...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(proxyEndpoint.Bytes()); got != converted {
+		t.Errorf("ProxyEndpoint after a re-run =\n%s\nwant\n%s", got, converted)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	mgconfig "mgw2egw/microgatewayconfig"
+	"time"
+)
+
+// Report is the machine readable summary of a run written with -report
+type Report struct {
+	Version            string        `json:"version"`
+	Org                string        `json:"org"`
+	Env                string        `json:"env"`
+	ConfigFile         string        `json:"configFile"`
+	ConfigSha256       string        `json:"configSha256"`
+	Plugins            []string      `json:"plugins"`
+	UnconvertedPlugins []string      `json:"unconvertedPlugins"`
+	ScaffoldedPlugins  []string      `json:"scaffoldedPlugins"`
+	Started            time.Time     `json:"started"`
+	DurationMs         int64         `json:"durationMs"`
+	ExitCode           int           `json:"exitCode"`
+	Proxies            []ProxyResult `json:"proxies"`
+}
+
+// writeReport writes the report of a run that exits with code
+func writeReport(fileName string, started time.Time, config mgconfig.Microgateway, results []ProxyResult, code int) error {
+
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(content)
+
+	report := Report{
+		Version:      version,
+		Org:          org,
+		Env:          env,
+		ConfigFile:   configFile,
+		ConfigSha256: hex.EncodeToString(hash[:]),
+		Plugins:      mgconfig.GetPlugins(config),
+		Started:      started,
+		DurationMs:   time.Since(started).Nanoseconds() / int64(time.Millisecond),
+		ExitCode:     code,
+		Proxies:      results,
+	}
+
+	//the proxies record what happened to each plugin, a custom plugin is
+	//only unconverted when no scaffold could be generated for it
+	for _, result := range results {
+		report.UnconvertedPlugins = appendMissing(report.UnconvertedPlugins, result.Unconverted...)
+		report.ScaffoldedPlugins = appendMissing(report.ScaffoldedPlugins, result.Scaffolded...)
+	}
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, append(output, '\n'), 0644)
+}
+
+// appendMissing appends the values that are not in list yet
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		if !contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	apigee "github.com/srinandan/go-apigee-edge"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// Status is the outcome of the conversion of a proxy
+type Status int
+
+const (
+	Skipped Status = iota
+	//Planned is a proxy converted with -plan, nothing was written or imported
+	Planned
+	Converted
+	Imported
+	Deployed
+	Failed
+)
+
+// exitFailed is the exit code when at least one proxy failed
+const exitFailed int = 2
+
+func (status Status) String() string {
+	switch status {
+	case Skipped:
+		return "skipped"
+	case Planned:
+		return "planned"
+	case Converted:
+		return "converted"
+	case Imported:
+		return "imported"
+	case Deployed:
+		return "deployed"
+	case Failed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// MarshalText writes the status by name in the JSON report
+func (status Status) MarshalText() ([]byte, error) {
+	return []byte(status.String()), nil
+}
+
+// ProxyResult records what happened to one proxy
+type ProxyResult struct {
+	Proxy          string          `json:"proxy"`
+	Status         Status          `json:"status"`
+	Reason         string          `json:"reason,omitempty"`
+	SourceRevision apigee.Revision `json:"sourceRevision,omitempty"`
+	NewRevision    apigee.Revision `json:"newRevision,omitempty"`
+	Plugins        []string        `json:"plugins,omitempty"`
+	Unconverted    []string        `json:"unconvertedPlugins,omitempty"`
+	Scaffolded     []string        `json:"scaffoldedPlugins,omitempty"`
+	Policies       []string        `json:"policies,omitempty"`
+	DeployResult   string          `json:"deployResult,omitempty"`
+	Started        time.Time       `json:"started"`
+	DurationMs     int64           `json:"durationMs"`
+}
+
+func (result ProxyResult) skip(reason string) ProxyResult {
+	result.Status = Skipped
+	result.Reason = reason
+	return result
+}
+
+func (result ProxyResult) fail(message string, err error) ProxyResult {
+	Error.Println(message, ": ", err)
+	result.Status = Failed
+	result.Reason = fmt.Sprintf("%s: %v", message, err)
+	return result
+}
+
+// printSummary prints a table with the outcome of every proxy
+func printSummary(results []ProxyResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROXY\tSTATUS\tREVISION\tREASON")
+	for _, result := range results {
+		revision := ""
+		if result.SourceRevision != 0 {
+			revision = fmt.Sprintf("%d", result.SourceRevision)
+		}
+		if result.NewRevision != 0 {
+			revision += fmt.Sprintf(" -> %d", result.NewRevision)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Proxy, result.Status, revision, result.Reason)
+	}
+	w.Flush()
+}
+
+// exitCode returns 0 unless a proxy failed
+func exitCode(results []ProxyResult) int {
+	for _, result := range results {
+		if result.Status == Failed {
+			return exitFailed
+		}
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines "1" to "n", with values replacing some of them
+func numbered(n int, values map[int]string) string {
+	var lines []string
+	for i := 1; i <= n; i++ {
+		line, ok := values[i]
+		if !ok {
+			line = strconv.Itoa(i)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{
+			name: "identical",
+			from: numbered(3, nil),
+			to:   numbered(3, nil),
+			want: "",
+		},
+		{
+			name: "pure insert",
+			from: "",
+			to:   "a\nb\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "pure delete",
+			from: "a\nb\n",
+			to:   "",
+			want: "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "hunk at start of file",
+			from: numbered(6, nil),
+			to:   numbered(6, map[int]string{1: "x"}),
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n",
+		},
+		{
+			name: "hunk at end of file",
+			from: numbered(6, nil),
+			to:   numbered(6, map[int]string{6: "y"}),
+			want: "--- a\n+++ b\n@@ -3,4 +3,4 @@\n 3\n 4\n 5\n-6\n+y\n",
+		},
+		{
+			name: "nearby hunks are merged",
+			from: numbered(10, nil),
+			to:   numbered(10, map[int]string{2: "b", 8: "h"}),
+			want: "--- a\n+++ b\n@@ -1,10 +1,10 @@\n 1\n-2\n+b\n 3\n 4\n 5\n 6\n 7\n-8\n+h\n 9\n 10\n",
+		},
+		{
+			name: "distant hunks are separate",
+			from: numbered(12, nil),
+			to:   numbered(12, map[int]string{2: "b", 11: "k"}),
+			want: "--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+b\n 3\n 4\n 5\n@@ -8,5 +8,5 @@\n 8\n 9\n 10\n-11\n+k\n 12\n",
+		},
+	}
+
+	for _, test := range tests {
+		if got := UnifiedDiff("a", "b", []byte(test.from), []byte(test.to)); got != test.want {
+			t.Errorf("%s: diff is\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
@@ -16,119 +16,16 @@ package utils
 
 import (
 	"archive/zip"
+	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 )
 
-const oauthPath string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<OAuthV2 async="false" continueOnError="false" enabled="true" name="OAuth-v20-1">
-    <DisplayName>OAuth v2.0-1</DisplayName>
-    <Properties/>
-    <Attributes/>
-    <ExternalAuthorization>false</ExternalAuthorization>
-    <Operation>VerifyAccessToken</Operation>
-    <SupportedGrantTypes/>
-    <GenerateResponse enabled="true"/>
-    <Tokens/>
-</OAuthV2>`
-const oauthName string = "/OAuth-v20-1.xml"
-
-const quotaPathAPIKey string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<Quota async="false" continueOnError="false" enabled="true" name="Quota-1" type="calendar">
-    <DisplayName>Quota-1</DisplayName>
-    <Properties/>
-    <Allow count="200" countRef="verifyapikey.Verify-API-Key-1.apiproduct.developer.quota.limit"/>
-    <Interval ref="verifyapikey.Verify-API-Key-1.apiproduct.developer.quota.interval">1</Interval>
-    <Distributed>true</Distributed>
-    <Synchronous>true</Synchronous>
-	<PreciseAtSecondsLevel>false</PreciseAtSecondsLevel>
-    <TimeUnit ref="verifyapikey.Verify-API-Key-1.apiproduct.developer.quota.timeunit">hour</TimeUnit>
-</Quota>`
-const quotaPathOAuth string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<Quota async="false" continueOnError="false" enabled="true" name="Quota-1" type="calendar">
-    <DisplayName>Quota-1</DisplayName>
-    <Properties/>
-    <Allow count="200" countRef="apiproduct.developer.quota.limit"/>
-    <Interval ref="apiproduct.developer.quota.interval">1</Interval>
-    <Distributed>true</Distributed>
-    <Synchronous>true</Synchronous>
-	<PreciseAtSecondsLevel>false</PreciseAtSecondsLevel>
-    <TimeUnit ref="apiproduct.developer.quota.timeunit">hour</TimeUnit>
-</Quota>`
-const quotaName string = "/Quota-1.xml"
-
-var spikeArrestPath string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<SpikeArrest async="false" continueOnError="false" enabled="true" name="Spike-Arrest-1">
-    <DisplayName>Spike Arrest-1</DisplayName>
-    <Properties/>
-    <Rate>30ps</Rate>
-</SpikeArrest>`
-
-const spikeArrestName string = "/Spike-Arrest-1.xml"
-
-const verifyApiKeyPath string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<VerifyAPIKey async="false" continueOnError="false" enabled="true" name="Verify-API-Key-1">
-    <DisplayName>Verify API Key-1</DisplayName>
-    <Properties/>
-    <APIKey ref="request.header.x-api-key"/>
-</VerifyAPIKey>`
-
-const verifyApiKeyName string = "/Verify-API-Key-1.xml"
-
-const extractVarPath string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<ExtractVariables async="false" continueOnError="false" enabled="true" name="Extract-Variables-1">
-    <DisplayName>Extract Variables-1</DisplayName>
-    <Properties/>
-    <Header name="Authorization">
-        <Pattern ignoreCase="false">Bearer {oauthtoken}</Pattern>
-    </Header>
-    <IgnoreUnresolvedVariables>true</IgnoreUnresolvedVariables>
-    <Source clearPayload="false">request</Source>
-</ExtractVariables>`
-const extractVarName string = "/Extract-Variables-1.xml"
-
-const kvmPath string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<KeyValueMapOperations async="false" continueOnError="false" enabled="true" name="Key-Value-Map-Operations-1" mapIdentifier="microgateway">
-    <DisplayName>Key Value Map Operations-1</DisplayName>
-    <Properties/>
-    <ExclusiveCache>false</ExclusiveCache>
-    <ExpiryTimeInSecs>300</ExpiryTimeInSecs>
-    <Get assignTo="private.publicKey" index="1">
-        <Key>
-            <Parameter>public_key</Parameter>
-        </Key>
-    </Get>
-    <Scope>environment</Scope>
-</KeyValueMapOperations>`
-const kvmName string = "/Key-Value-Map-Operations-1.xml"
-
-const verifyJwtPath string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<VerifyJWT async="false" continueOnError="false" enabled="true" name="Verify-JWT-1">
-    <DisplayName>Verify JWT-1</DisplayName>
-    <Algorithm>RS256</Algorithm>
-    <Source>oauthtoken</Source>
-    <PublicKey>
-        <Value ref="private.publicKey"/>
-    </PublicKey>
-    <CustomClaims>
-        <Claim name="audience">microgateway</Claim>
-    </CustomClaims>
-</VerifyJWT>`
-const verifyJwtName string = "/Verify-JWT-1.xml"
-
-const verifyApiKeyPath2 string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<VerifyAPIKey async="false" continueOnError="false" enabled="true" name="Verify-API-Key-1">
-    <DisplayName>Verify API Key-1</DisplayName>
-    <Properties/>
-    <APIKey ref="jwt.Verify-JWT-1.custom_claim.client_id"/>
-</VerifyAPIKey>`
-
-// Unzip will uncompress a zip archive
+// Unzip will uncompress a zip archive. Entries with an absolute path or a
+// path that leads outside of dest are rejected.
 func Unzip(src, dest string) ([]string, error) {
 
 	var filenames []string
@@ -149,12 +46,18 @@ func Unzip(src, dest string) ([]string, error) {
 
 		// Store filename/path for returning and using later on
 		fpath := filepath.Join(dest, f.Name)
+		if !insideFolder(dest, f.Name) {
+			return filenames, fmt.Errorf("%s: entry %s is outside of the archive folder", src, f.Name)
+		}
 		filenames = append(filenames, fpath)
 
 		if f.FileInfo().IsDir() {
 
 			// Make Folder
-			os.MkdirAll(fpath, os.ModePerm)
+			err = os.MkdirAll(fpath, os.ModePerm)
+			if err != nil {
+				return filenames, err
+			}
 
 		} else {
 
@@ -166,7 +69,6 @@ func Unzip(src, dest string) ([]string, error) {
 
 			err = os.MkdirAll(fdir, os.ModePerm)
 			if err != nil {
-				log.Fatal(err)
 				return filenames, err
 			}
 			f, err := os.OpenFile(
@@ -186,91 +88,110 @@ func Unzip(src, dest string) ([]string, error) {
 	return filenames, nil
 }
 
-func readFile(fileName string) ([]byte, error) {
-	absFileName, _ := filepath.Abs(fileName)
-	content, err := ioutil.ReadFile(absFileName)
-	return content, err
+// insideFolder returns true when the relative path name stays inside folder
+func insideFolder(folder, name string) bool {
+	if filepath.IsAbs(name) || strings.HasPrefix(filepath.ToSlash(name), "/") {
+		return false
+	}
+	folder = filepath.Clean(folder)
+	fpath := filepath.Join(folder, name)
+	return fpath == folder || strings.HasPrefix(fpath, folder+string(os.PathSeparator))
 }
 
-func writeFile(fileName string, content []byte) error {
-	err := ioutil.WriteFile(fileName, content, 0777)
-	return err
-}
+// Zip will compress the contents of a folder into a zip archive
+func Zip(src, dest string) error {
 
-func CopyOAuth(folder string) error {
-	err := writeFile(folder+oauthName, []byte(oauthPath))
+	zipFile, err := os.Create(dest)
 	if err != nil {
 		return err
 	}
-	return nil
-}
+	defer zipFile.Close()
 
-func CopyQuota(folder string, oauth bool) error {
+	w := zip.NewWriter(zipFile)
 
-	var err error
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
 
-	if oauth {
-		err = writeFile(folder+quotaName, []byte(quotaPathOAuth))
-	} else {
-		err = writeFile(folder+quotaName, []byte(quotaPathAPIKey))
-	}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
 
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		header.Method = zip.Deflate
+
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(content)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return w.Close()
 }
 
-func CopySpikeArrest(folder string, Timeunit string, Allow int) error {
-	var rate string
+// CopyDir will recursively copy a folder
+func CopyDir(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 
-	if Timeunit == "minute" {
-		rate = strconv.Itoa(Allow) + "pm"
-	} else {
-		rate = strconv.Itoa(Allow) + "ps"
-	}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		fpath := filepath.Join(dest, relPath)
 
-	spikeArrestPath = strings.Replace(spikeArrestPath, "30ps", rate, 1)
+		if info.IsDir() {
+			return os.MkdirAll(fpath, os.ModePerm)
+		}
 
-	err := writeFile(folder+spikeArrestName, []byte(spikeArrestPath))
-	if err != nil {
-		return err
-	}
-	return nil
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(fpath, content, info.Mode())
+	})
 }
 
-func CopyAPIKey(folder string) error {
-	err := writeFile(folder+verifyApiKeyName, []byte(verifyApiKeyPath))
-	if err != nil {
-		return err
-	}
-	return nil
+func readFile(fileName string) ([]byte, error) {
+	absFileName, _ := filepath.Abs(fileName)
+	content, err := ioutil.ReadFile(absFileName)
+	return content, err
 }
 
-func CopyJWT(folder string) error {
-
-	err := writeFile(folder+verifyApiKeyName, []byte(verifyApiKeyPath))
-	if err != nil {
-		return err
-	}
-
-	err = writeFile(folder+kvmName, []byte(kvmPath))
-	if err != nil {
-		return err
-	}
-
-	err = writeFile(folder+verifyJwtName, []byte(verifyJwtPath))
-	if err != nil {
-		return err
-	}
+func writeFile(fileName string, content []byte) error {
+	err := ioutil.WriteFile(fileName, content, 0777)
+	return err
+}
 
-	err = writeFile(folder+extractVarName, []byte(extractVarPath))
-	if err != nil {
-		return err
-	}
+// WritePolicy writes a policy to <folder>/<name>.xml
+func WritePolicy(folder string, name string, content []byte) error {
+	return writeFile(folder+"/"+name+".xml", content)
+}
 
-	return nil
+// WriteScript writes a JavaScript resource to <folder>/<name>.js
+func WriteScript(folder string, name string, content []byte) error {
+	return writeFile(folder+"/"+name+".js", content)
 }
 
 func Cleanup(bundleName string, genOnly bool) error {
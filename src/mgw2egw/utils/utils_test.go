@@ -0,0 +1,67 @@
+// Copyright 2017 Apigee
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeZip creates an archive with one empty file per name
+func writeZip(t *testing.T, fileName string, names ...string) {
+	zipFile, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zipFile.Close()
+	w := zip.NewWriter(zipFile)
+	for _, name := range names {
+		if _, err = w.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnzipRejectsEscapingEntries(t *testing.T) {
+	folder, err := ioutil.TempDir("", "unzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	for _, name := range []string{"../evil.txt", "apiproxy/../../evil.txt", "/tmp/evil.txt"} {
+		archive := filepath.Join(folder, "bundle.zip")
+		writeZip(t, archive, "apiproxy/edgemicro_hello.xml", name)
+		dest := filepath.Join(folder, "dest")
+		if _, err := Unzip(archive, dest); err == nil {
+			t.Errorf("an archive with the entry %s was extracted", name)
+		}
+		if _, err := os.Stat(filepath.Join(folder, "evil.txt")); err == nil {
+			t.Errorf("the entry %s was written outside of the destination", name)
+		}
+	}
+
+	archive := filepath.Join(folder, "bundle.zip")
+	writeZip(t, archive, "apiproxy/edgemicro_hello.xml", "apiproxy/policies/../proxies/default.xml")
+	if _, err := Unzip(archive, filepath.Join(folder, "ok")); err != nil {
+		t.Errorf("a valid archive was rejected: %v", err)
+	}
+}